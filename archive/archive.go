@@ -0,0 +1,199 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type Format string
+
+const (
+	Zip    Format = "zip"
+	TarGz  Format = "tar.gz"
+	TarZst Format = "tar.zst"
+)
+
+var (
+	ErrUnknownFormat = fmt.Errorf("unknown archive format")
+	ErrUnsafePath    = fmt.Errorf("archive entry escapes the destination folder")
+)
+
+// Progress is called after each archived file with the number of files and bytes archived so far
+type Progress func(files int, bytes int64)
+
+type Options struct {
+	Progress Progress // may be nil
+	Exclude  *Rules   // entries left out of the archive, may be nil
+}
+
+// Archiver writes and extracts archives of a folder
+//
+// file modes and symlinks are kept, hardlinks are dereferenced and special files (ex: named pipes) are ignored
+type Archiver interface {
+	// Archive writes the content of srcFolder to wr (it does NOT close wr)
+	Archive(srcFolder string, wr io.Writer, opts Options) error
+	// Extract extracts the archive file src into dstFolder (created if needed)
+	//
+	// entries that would be written outside of dstFolder (zip slip), including through symlinks,
+	// make Extract fail with ErrUnsafePath
+	Extract(src string, dstFolder string) error
+}
+
+// New returns the archiver of format f
+func New(f Format) (Archiver, error) {
+	switch f {
+	case Zip:
+		return zipArchiver{}, nil
+	case TarGz:
+		return tarArchiver{compress: gzipWriter, decompress: gzipReader}, nil
+	case TarZst:
+		return tarArchiver{compress: zstdWriter, decompress: zstdReader}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, f)
+}
+
+func (f Format) Valid() bool {
+	_, err := New(f)
+	return err == nil
+}
+
+// Extension returns the file extension of the format, with its dot
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// ContentType returns the media type of archives of the format
+func (f Format) ContentType() string {
+	switch f {
+	case Zip:
+		return "application/zip"
+	case TarGz:
+		return "application/gzip"
+	case TarZst:
+		return "application/zstd"
+	}
+	return "application/octet-stream"
+}
+
+// Archive writes the content of srcFolder to wr in format f, see Archiver
+func Archive(f Format, srcFolder string, wr io.Writer, opts Options) error {
+	a, err := New(f)
+	if err != nil {
+		return err
+	}
+	return a.Archive(srcFolder, wr, opts)
+}
+
+// Extract extracts the archive file src into dstFolder, its format is detected from its content
+func Extract(src string, dstFolder string) error {
+	f, err := Detect(src)
+	if err != nil {
+		return err
+	}
+	a, err := New(f)
+	if err != nil {
+		return err
+	}
+	return a.Extract(src, dstFolder)
+}
+
+var magics = []struct {
+	format Format
+	magic  []byte
+}{
+	{Zip, []byte("PK\x03\x04")},
+	{Zip, []byte("PK\x05\x06")}, // empty archive
+	{TarGz, []byte{0x1f, 0x8b}},
+	{TarZst, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// Detect returns the format of the archive file from its first bytes
+func Detect(file string) (Format, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var buf = make([]byte, 4)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+	}
+	for _, m := range magics {
+		if bytes.HasPrefix(buf[:n], m.magic) {
+			return m.format, nil
+		}
+	}
+	return "", ErrUnknownFormat
+}
+
+// walk calls f for every entry of srcFolder to archive (with its slash separated relative path),
+// skipping the folder itself, special files and excluded entries
+func walk(srcFolder string, exclude *Rules, f func(rel string, path string, info fs.FileInfo) error) error {
+	srcFolder, err := filepath.Abs(srcFolder)
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(srcFolder)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%v is not a folder", srcFolder)
+	}
+	return filepath.WalkDir(srcFolder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == srcFolder {
+			return nil
+		}
+		rel, err := filepath.Rel(srcFolder, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var mode = info.Mode()
+		if !mode.IsDir() && !mode.IsRegular() && mode&os.ModeSymlink == 0 {
+			fmt.Printf("ignoring special file %s...\n", path) // ex: named pipes, which would block
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if exclude.Excluded(rel, mode.IsDir()) {
+			if mode.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return f(rel, path, info)
+	})
+}
+
+// Size returns the number of bytes of the regular files that an archive of srcFolder would contain
+func Size(srcFolder string, exclude *Rules) (int64, error) {
+	var size int64
+	err := walk(srcFolder, exclude, func(rel string, path string, info fs.FileInfo) error {
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// copyFile writes the content of the file at path to wr and returns the number of bytes written
+func copyFile(wr io.Writer, path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(wr, f)
+}
@@ -0,0 +1,240 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// extractor writes archive entries into a folder, refusing any entry that would end up outside of it
+//
+// symlinks are only created once every other entry is extracted (so that no entry can be written
+// through one) and folder modes are applied last (so that read-only folders can be filled); folders
+// are always left writable by their owner so that extracted trees can be moved and removed
+type extractor struct {
+	folder string
+	links  []link
+	dirs   []dirEntry
+}
+
+type link struct {
+	path   string
+	target string
+}
+
+type dirEntry struct {
+	path    string
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func newExtractor(folder string) (*extractor, error) {
+	folder, err := filepath.Abs(folder)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(folder, 0755)
+	if err != nil {
+		return nil, err
+	}
+	// the folder itself may be behind a symlink (ex: /tmp on macOS)
+	folder, err = filepath.EvalSymlinks(folder)
+	if err != nil {
+		return nil, err
+	}
+	return &extractor{folder: folder}, nil
+}
+
+// safeJoin returns the path of the archive entry name inside the folder
+func (e *extractor) safeJoin(name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("%w: %v", ErrUnsafePath, name)
+	}
+	var path = filepath.Join(e.folder, filepath.FromSlash(name))
+	if !e.inside(path) || path == e.folder {
+		return "", fmt.Errorf("%w: %v", ErrUnsafePath, name)
+	}
+	return path, nil
+}
+
+func (e *extractor) inside(path string) bool {
+	return inside(e.folder, path)
+}
+
+func inside(folder string, path string) bool {
+	return path == folder || strings.HasPrefix(path, folder+string(filepath.Separator))
+}
+
+func (e *extractor) ignore(name string) {
+	fmt.Printf("ignoring special entry %s...\n", name)
+}
+
+func (e *extractor) dir(name string, mode os.FileMode, modTime time.Time) error {
+	path, err := e.safeJoin(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return err
+	}
+	e.dirs = append(e.dirs, dirEntry{path, mode.Perm(), modTime})
+	return nil
+}
+
+func (e *extractor) file(name string, mode os.FileMode, modTime time.Time, rd io.Reader) error {
+	path, err := e.safeJoin(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, rd)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	err = dst.Close()
+	if err == nil {
+		err = os.Chmod(path, mode.Perm())
+	}
+	if err != nil || modTime.IsZero() {
+		return err
+	}
+	return os.Chtimes(path, modTime, modTime)
+}
+
+func (e *extractor) symlink(name string, target string) error {
+	path, err := e.safeJoin(name)
+	if err != nil {
+		return err
+	}
+	if target == "" || filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
+		return fmt.Errorf("%w: %v links to %v", ErrUnsafePath, name, target)
+	}
+	e.links = append(e.links, link{path, filepath.FromSlash(target)})
+	return nil
+}
+
+// hardlink links name to the already extracted entry target (relative to the root of the archive)
+func (e *extractor) hardlink(name string, target string) error {
+	path, err := e.safeJoin(name)
+	if err != nil {
+		return err
+	}
+	old, err := e.safeJoin(target)
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(old)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %v links to %v", ErrUnsafePath, name, target)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	os.Remove(path)
+	return os.Link(old, path)
+}
+
+// CheckLinks fails with ErrUnsafePath if a symlink inside folder leads outside of it, such a link is removed
+//
+// links that do not lead to an existing file are only accepted if their target has no ".." component
+func CheckLinks(folder string) error {
+	folder, err := filepath.Abs(folder)
+	if err == nil {
+		folder, err = filepath.EvalSymlinks(folder)
+	}
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.Type()&fs.ModeSymlink == 0 {
+			return err
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil && inside(folder, real) {
+			return nil
+		}
+		if os.IsNotExist(err) && !hasParentRef(target) {
+			return nil
+		}
+		os.Remove(path)
+		var rel, _ = filepath.Rel(folder, path)
+		return fmt.Errorf("%w: %v links to %v", ErrUnsafePath, filepath.ToSlash(rel), filepath.ToSlash(target))
+	})
+}
+
+func hasParentRef(target string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(target), "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// finish creates the symlinks and applies folder modes
+func (e *extractor) finish() error {
+	for _, l := range e.links {
+		err := os.MkdirAll(filepath.Dir(l.path), 0755)
+		if err != nil {
+			return err
+		}
+		// the parent may be (or be inside) a symlink created before, targets are resolved from the real folder
+		parent, err := filepath.EvalSymlinks(filepath.Dir(l.path))
+		if err != nil {
+			return err
+		}
+		var rel, _ = filepath.Rel(e.folder, l.path)
+		if !e.inside(parent) || !e.inside(filepath.Join(parent, l.target)) {
+			return fmt.Errorf("%w: %v links to %v", ErrUnsafePath, filepath.ToSlash(rel), filepath.ToSlash(l.target))
+		}
+		var path = filepath.Join(parent, filepath.Base(l.path))
+		if path == e.folder {
+			return fmt.Errorf("%w: %v", ErrUnsafePath, filepath.ToSlash(rel))
+		}
+		err = os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		err = os.Symlink(l.target, path)
+		if err != nil {
+			return err
+		}
+	}
+	// the lexical check above does not see links going through links created before (ex: a/s -> .. then a/t -> s/../..)
+	err := CheckLinks(e.folder)
+	if err != nil {
+		return err
+	}
+	for i := len(e.dirs) - 1; i >= 0; i-- {
+		var d = e.dirs[i]
+		err := os.Chmod(d.path, d.mode|0200)
+		if err == nil && !d.modTime.IsZero() {
+			err = os.Chtimes(d.path, d.modTime, d.modTime)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// entry of a tar archive built by writeTar
+type entry struct {
+	name string
+	body string // content of a regular file
+	link string // target of a symlink (or of a hardlink if hard is set)
+	hard bool
+	dir  bool
+	mode int64
+}
+
+func writeTar(t *testing.T, entries []entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var gz = gzip.NewWriter(&buf)
+	var tw = tar.NewWriter(gz)
+	for _, e := range entries {
+		var hdr = &tar.Header{Name: e.name, Mode: e.mode}
+		switch {
+		case e.dir:
+			hdr.Typeflag = tar.TypeDir
+		case e.hard:
+			hdr.Typeflag, hdr.Linkname = tar.TypeLink, e.link
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.link
+		default:
+			hdr.Typeflag, hdr.Size = tar.TypeReg, int64(len(e.body))
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	var file = filepath.Join(t.TempDir(), "archive.tar.gz")
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// writeZip is writeTar for zip archives (links are not supported)
+func writeZip(t *testing.T, entries []entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var zw = zip.NewWriter(&buf)
+	for _, e := range entries {
+		var hdr = &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		var mode = os.FileMode(e.mode)
+		if mode == 0 {
+			mode = 0644
+		}
+		if e.dir {
+			mode |= os.ModeDir
+		}
+		hdr.SetMode(mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	zw.Close()
+	var file = filepath.Join(t.TempDir(), "archive.zip")
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	var tests = []struct {
+		name    string
+		entries []entry
+	}{
+		{"parent", []entry{{name: "../evil", body: "x"}}},
+		{"nested parent", []entry{{name: "a/../../evil", body: "x"}}},
+		{"absolute", []entry{{name: "/tmp/evil", body: "x"}}},
+		{"absolute symlink", []entry{{name: "a", link: "/etc"}}},
+		{"symlink upward", []entry{{name: "a", link: ".."}}},
+		{"nested symlink upward", []entry{{name: "a/b", link: "../.."}}},
+		{"write through symlink", []entry{{name: "a", link: "."}, {name: "a/../../evil", body: "x"}}},
+		{"hardlink outside", []entry{{name: "a", link: "../evil", hard: true}}},
+		// each link is lexically inside, but t goes through s: a/s/../.. is two levels above the folder
+		{"symlink chain", []entry{{name: "a/s", link: ".."}, {name: "a/t", link: "s/../.."}}},
+		{"symlink chain reversed", []entry{{name: "a/t", link: "s/../.."}, {name: "a/s", link: ".."}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var file = writeTar(t, test.entries)
+			var dst = filepath.Join(t.TempDir(), "out")
+			err := Extract(file, dst)
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Fatalf("expected ErrUnsafePath, got %v", err)
+			}
+			if _, err := os.Lstat(filepath.Join(dst, "a", "t")); err == nil {
+				t.Fatal("unsafe link was kept")
+			}
+		})
+	}
+}
+
+func TestExtractZipUnsafe(t *testing.T) {
+	for _, name := range []string{"../evil", "a/../../evil", "/tmp/evil"} {
+		t.Run(name, func(t *testing.T) {
+			var file = writeZip(t, []entry{{name: name, body: "x"}})
+			err := Extract(file, filepath.Join(t.TempDir(), "out"))
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Fatalf("expected ErrUnsafePath, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractLinksInside(t *testing.T) {
+	var file = writeTar(t, []entry{
+		{name: "world", dir: true, mode: 0755},
+		{name: "world/level.dat", body: "level"},
+		{name: "current", link: "world"},
+		{name: "world/up", link: ".."},
+		{name: "world/dat", link: "../current/level.dat"},
+		{name: "dangling", link: "missing/file"},
+		{name: "copy.dat", link: "world/level.dat", hard: true},
+	})
+	var dst = t.TempDir()
+	err := Extract(file, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"world/dat", "copy.dat", "world/up/current/level.dat"} {
+		data, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil || string(data) != "level" {
+			t.Errorf("%v: got %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestExtractReadOnlyFolder(t *testing.T) {
+	var entries = []entry{
+		{name: "ro/", dir: true, mode: 0555},
+		{name: "ro/sub/", dir: true, mode: 0500},
+		{name: "ro/sub/file", body: "x", mode: 0444},
+	}
+	for format, file := range map[string]string{"tar": writeTar(t, entries), "zip": writeZip(t, entries)} {
+		t.Run(format, func(t *testing.T) {
+			var dst = t.TempDir()
+			err := Extract(file, dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range map[string]os.FileMode{"ro": 0755, "ro/sub": 0700, "ro/sub/file": 0444} {
+				info, err := os.Stat(filepath.Join(dst, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm() != want {
+					t.Errorf("%v: got mode %v, want %v (folders must stay writable by their owner)", name, info.Mode().Perm(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var src = t.TempDir()
+	os.MkdirAll(filepath.Join(src, "world", "region"), 0755)
+	os.WriteFile(filepath.Join(src, "world", "level.dat"), []byte("level"), 0600)
+	os.WriteFile(filepath.Join(src, "world", "region", "r.0.0.mca"), bytes.Repeat([]byte("r"), 100000), 0644)
+	os.WriteFile(filepath.Join(src, "server.jar"), []byte("jar"), 0644)
+	os.Symlink("world/level.dat", filepath.Join(src, "level"))
+	rules, err := ParseRules([]string{"/server.jar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []Format{Zip, TarGz, TarZst} {
+		t.Run(string(f), func(t *testing.T) {
+			var file = filepath.Join(t.TempDir(), "archive"+f.Extension())
+			out, err := os.Create(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Archive(f, src, out, Options{Exclude: rules})
+			out.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if detected, err := Detect(file); err != nil || detected != f {
+				t.Fatalf("detected %v, %v", detected, err)
+			}
+			var dst = t.TempDir()
+			err = Extract(file, dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Lstat(filepath.Join(dst, "server.jar")); !os.IsNotExist(err) {
+				t.Error("excluded file was archived")
+			}
+			info, err := os.Stat(filepath.Join(dst, "world", "level.dat"))
+			if err != nil || info.Mode().Perm() != 0600 {
+				t.Errorf("level.dat: %v, %v", info, err)
+			}
+			target, err := os.Readlink(filepath.Join(dst, "level"))
+			if err != nil || target != "world/level.dat" {
+				t.Errorf("symlink: %q, %v", target, err)
+			}
+			data, err := os.ReadFile(filepath.Join(dst, "world", "region", "r.0.0.mca"))
+			if err != nil || len(data) != 100000 {
+				t.Errorf("region: %v bytes, %v", len(data), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package archive
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidRule = fmt.Errorf("invalid rule")
+
+// Rules tells which entries of a folder are left out of its archives, with gitignore-style patterns:
+//
+//   - blank lines and lines starting with # are ignored, a leading \ escapes # and !
+//   - a pattern starting with ! includes again what a previous pattern excluded
+//   - a pattern ending with / only matches folders
+//   - a pattern containing a / (ex: /server.jar, plugins/dynmap) is relative to the folder,
+//     other patterns match entries at any depth (ex: *.tmp)
+//   - * and ? match anything but /, ** matches any number of folders and [a-z] a character class
+//
+// the last matching pattern wins; as with git, nothing inside an excluded folder can be included again
+type Rules struct {
+	rules []rule
+}
+
+type rule struct {
+	reg     *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ParseRules compiles patterns, see Rules
+func ParseRules(patterns []string) (*Rules, error) {
+	var r = &Rules{}
+	for _, p := range patterns {
+		rule, ok, err := parseRule(p)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidRule, p, err)
+		}
+		if ok {
+			r.rules = append(r.rules, rule)
+		}
+	}
+	return r, nil
+}
+
+// parseRule returns false if p is blank or a comment
+func parseRule(p string) (rule, bool, error) {
+	var r rule
+	p = strings.TrimRight(p, " \t\r")
+	if p == "" || strings.HasPrefix(p, "#") {
+		return r, false, nil
+	}
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	} else if strings.HasPrefix(p, `\!`) || strings.HasPrefix(p, `\#`) {
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	var anchored = strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return r, false, fmt.Errorf("empty pattern")
+	}
+	var expr = "^(?:.*/)?"
+	if anchored {
+		expr = "^"
+	}
+	reg, err := regexp.Compile(expr + globToRegexp(p) + "$")
+	r.reg = reg
+	return r, true, err
+}
+
+func globToRegexp(p string) string {
+	var sb strings.Builder
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case strings.HasPrefix(p[i:], "**/") && (i == 0 || p[i-1] == '/'):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '\\' && i+1 < len(p):
+			i++
+			sb.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		case c == '[':
+			var end = strings.IndexByte(p[i+1:], ']')
+			if end <= 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			var class = p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	return sb.String()
+}
+
+// Excluded reports whether the entry at the slash separated path rel must be left out
+//
+// the parents of rel are not checked: callers must not descend into excluded folders; a nil Rules excludes nothing
+func (r *Rules) Excluded(rel string, dir bool) bool {
+	if r == nil {
+		return false
+	}
+	var excluded bool
+	for _, rule := range r.rules {
+		if rule.dirOnly && !dir {
+			continue
+		}
+		if rule.reg.MatchString(rel) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
@@ -0,0 +1,69 @@
+package archive
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExcluded(t *testing.T) {
+	var tests = []struct {
+		name     string
+		patterns []string
+		rel      string
+		dir      bool
+		excluded bool
+	}{
+		{"nil rules", nil, "world", true, false},
+		{"comment", []string{"# world", ""}, "world", true, false},
+
+		// anchored
+		{"anchored root", []string{"/server.jar"}, "server.jar", false, true},
+		{"anchored not nested", []string{"/server.jar"}, "plugins/server.jar", false, false},
+		{"slash inside", []string{"plugins/dynmap"}, "plugins/dynmap", true, true},
+		{"slash inside not nested", []string{"plugins/dynmap"}, "a/plugins/dynmap", true, false},
+		{"unanchored nested", []string{"*.tmp"}, "world/region/r.tmp", false, true},
+		{"star stops at slash", []string{"/world*"}, "world/level.dat", false, false},
+		{"double star", []string{"/world/**/*.mca"}, "world/a/b/r.0.0.mca", false, true},
+		{"double star no folder", []string{"/world/**/*.mca"}, "world/r.0.0.mca", false, true},
+		{"question mark", []string{"r.?.mca"}, "r.1.mca", false, true},
+		{"class", []string{"log[0-9]"}, "log5", false, true},
+		{"negated class", []string{"log[!0-9]"}, "log5", false, false},
+
+		// dir only
+		{"dir only folder", []string{"logs/"}, "logs", true, true},
+		{"dir only file", []string{"logs/"}, "logs", false, false},
+		{"dir only nested", []string{"cache/"}, "plugins/x/cache", true, true},
+		{"dir only anchored", []string{"/logs/"}, "a/logs", true, false},
+
+		// negated
+		{"negated", []string{"*.jar", "!/server.jar"}, "server.jar", false, false},
+		{"negated other", []string{"*.jar", "!/server.jar"}, "plugins/a.jar", false, true},
+		{"last wins", []string{"!/server.jar", "*.jar"}, "server.jar", false, true},
+		{"negated dir only", []string{"/world*/", "!/world_nether/"}, "world_nether", true, false},
+		{"escaped bang", []string{`\!important`}, "!important", false, true},
+		{"escaped hash", []string{`\#notes`}, "#notes", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var rules *Rules
+			if test.patterns != nil {
+				var err error
+				rules, err = ParseRules(test.patterns)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := rules.Excluded(test.rel, test.dir); got != test.excluded {
+				t.Errorf("%v on %q (dir %v): got %v", test.patterns, test.rel, test.dir, got)
+			}
+		})
+	}
+}
+
+func TestParseRulesInvalid(t *testing.T) {
+	for _, p := range []string{"/", "!", "!/", "[z-a]"} {
+		if _, err := ParseRules([]string{p}); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("%q: expected ErrInvalidRule, got %v", p, err)
+		}
+	}
+}
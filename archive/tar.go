@@ -0,0 +1,128 @@
+package archive
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// tarArchiver writes tar archives through a compression layer
+type tarArchiver struct {
+	compress   func(io.Writer) (io.WriteCloser, error)
+	decompress func(io.Reader) (io.ReadCloser, error)
+}
+
+func gzipWriter(wr io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(wr), nil
+}
+
+func gzipReader(rd io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(rd)
+}
+
+func zstdWriter(wr io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(wr)
+}
+
+func zstdReader(rd io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(rd)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+func (a tarArchiver) Archive(srcFolder string, wr io.Writer, opts Options) error {
+	var files int
+	var bytes int64
+	cw, err := a.compress(wr)
+	if err != nil {
+		return err
+	}
+	dst := tar.NewWriter(cw)
+	err = walk(srcFolder, opts.Exclude, func(rel string, path string, info fs.FileInfo) error {
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			var err error
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		err = dst.WriteHeader(hdr)
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		n, err := copyFile(dst, path)
+		files++
+		bytes += n
+		if opts.Progress != nil {
+			opts.Progress(files, bytes)
+		}
+		return err
+	})
+	if err == nil {
+		err = dst.Close()
+	}
+	if err != nil {
+		cw.Close()
+		return err
+	}
+	return cw.Close()
+}
+
+func (a tarArchiver) Extract(src string, dstFolder string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rd, err := a.decompress(f)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	ex, err := newExtractor(dstFolder)
+	if err != nil {
+		return err
+	}
+	r := tar.NewReader(rd)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var mode = hdr.FileInfo().Mode()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = ex.dir(hdr.Name, mode, hdr.ModTime)
+		case tar.TypeReg, tar.TypeRegA:
+			err = ex.file(hdr.Name, mode, hdr.ModTime, r)
+		case tar.TypeSymlink:
+			err = ex.symlink(hdr.Name, hdr.Linkname)
+		case tar.TypeLink:
+			err = ex.hardlink(hdr.Name, hdr.Linkname)
+		default:
+			ex.ignore(hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ex.finish()
+}
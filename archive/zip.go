@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// maximum length of a symlink target read from an archive
+const maxLinkLen = 4096
+
+type zipArchiver struct{}
+
+func (zipArchiver) Archive(srcFolder string, wr io.Writer, opts Options) error {
+	var files int
+	var bytes int64
+	dst := zip.NewWriter(wr)
+	err := walk(srcFolder, opts.Exclude, func(rel string, path string, info fs.FileInfo) error {
+		var hdr = &zip.FileHeader{
+			Name:     rel,
+			Method:   zip.Deflate,
+			Modified: info.ModTime(),
+		}
+		hdr.SetMode(info.Mode())
+		switch {
+		case info.IsDir():
+			hdr.Name += "/"
+			hdr.Method = zip.Store
+			_, err := dst.CreateHeader(hdr)
+			return err
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			hdr.Method = zip.Store
+			w, err := dst.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, target)
+			return err
+		}
+		hdr.UncompressedSize64 = uint64(info.Size())
+		w, err := dst.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		n, err := copyFile(w, path)
+		files++
+		bytes += n
+		if opts.Progress != nil {
+			opts.Progress(files, bytes)
+		}
+		return err
+	})
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func (zipArchiver) Extract(src string, dstFolder string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	ex, err := newExtractor(dstFolder)
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		var name = strings.ReplaceAll(f.Name, `\`, "/") // archives made on windows
+		var mode = f.Mode()
+		if mode.Perm() == 0 { // permissions not stored by the archiver
+			mode |= 0644
+			if mode.IsDir() {
+				mode |= 0111
+			}
+		}
+		switch {
+		case mode.IsDir():
+			err = ex.dir(name, mode, f.Modified)
+		case mode&os.ModeSymlink != 0:
+			var target string
+			target, err = readLink(f)
+			if err == nil {
+				err = ex.symlink(name, target)
+			}
+		case mode.IsRegular():
+			err = extractZipFile(ex, f, name, mode)
+		default:
+			ex.ignore(f.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ex.finish()
+}
+
+func extractZipFile(ex *extractor, f *zip.File, name string, mode os.FileMode) error {
+	rd, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	return ex.file(name, mode, f.Modified, rd)
+}
+
+func readLink(f *zip.File) (string, error) {
+	rd, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rd.Close()
+	target, err := io.ReadAll(io.LimitReader(rd, maxLinkLen))
+	return string(target), err
+}
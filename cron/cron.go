@@ -0,0 +1,179 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidExpression = fmt.Errorf("invalid cron expression")
+
+	macros = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+
+	monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	dayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// Next gives up after this duration (ex: "0 0 30 2 *" never matches)
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var fields = []field{
+	{0, 59, nil},        // minute
+	{0, 23, nil},        // hour
+	{1, 31, nil},        // day of month
+	{1, 12, monthNames}, // month
+	{0, 7, dayNames},    // day of week (0 and 7 are sunday)
+}
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// when both day fields are restricted, a day matches if either of them does
+	domStar, dowStar bool
+}
+
+// Parse parses a standard 5 fields cron expression (minute hour day-of-month month day-of-week)
+//
+// fields support *, lists (1,2), ranges (1-5), steps (*/15, 1-30/2) and names for months and days (jan, mon),
+// @yearly, @monthly, @weekly, @daily and @hourly can also be used
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if m, ok := macros[strings.ToLower(expr)]; ok {
+		expr = m
+	}
+	var parts = strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("%w: expected %v fields but got %v", ErrInvalidExpression, len(fields), len(parts))
+	}
+	var sets = make([]uint64, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	// sunday can be written 0 or 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &Schedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func parseField(str string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(str, ",") {
+		var rng, stepStr, hasStep = strings.Cut(item, "/")
+		var step = 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("%w: invalid step in %q", ErrInvalidExpression, item)
+			}
+		}
+		var lo, hi int
+		if rng == "*" {
+			lo, hi = f.min, f.max
+		} else {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			lo, err = parseValue(a, f)
+			if err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				hi, err = parseValue(b, f)
+				if err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max // 5/15 means 5-max/15
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("%w: invalid range %q", ErrInvalidExpression, item)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func parseValue(str string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(str)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(str)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%w: invalid value %q", ErrInvalidExpression, str)
+	}
+	return v, nil
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	var dom = has(s.dom, t.Day())
+	var dow = has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time strictly after t matching the schedule (in the location of t)
+//
+// returns the zero time if there is none in the next 5 years
+func (s *Schedule) Next(t time.Time) time.Time {
+	var limit = t.Add(searchLimit)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
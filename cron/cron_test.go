@@ -0,0 +1,97 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		expr  string
+		valid bool
+	}{
+		{"* * * * *", true},
+		{"0-59/10 0,12 1-15 jan-mar sun,sat", true},
+		{"5/20 * * * *", true},
+		{"0 0 * * 7", true},
+		{"@yearly", true},
+		{"  @Daily ", true},
+		{"", false},
+		{"* * * *", false},
+		{"* * * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"*/0 * * * *", false},
+		{"*/x * * * *", false},
+		{"5-1 * * * *", false},
+		{"a * * * *", false},
+		{"1,,2 * * * *", false},
+		{"* * * foo *", false},
+		{"@every", false},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.expr)
+		if test.valid && err != nil {
+			t.Errorf("%q: unexpected error %v", test.expr, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidExpression) {
+			t.Errorf("%q: expected ErrInvalidExpression, got %v", test.expr, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// a sunday
+	var from = time.Date(2026, 10, 18, 10, 30, 15, 0, time.UTC)
+	var tests = []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 18, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 18, 10, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2026, 10, 18, 10, 45, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)},
+		// strictly after from
+		{"30 10 * * *", time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		// both day fields restricted: either one matches (the 13th or a friday)
+		{"0 0 13 * fri", time.Date(2026, 10, 23, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * jan *", time.Date(2027, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		s, err := Parse(test.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", test.expr, err)
+		}
+		if next := s.Next(from); !next.Equal(test.next) {
+			t.Errorf("%q: got %v, want %v", test.expr, next, test.next)
+		}
+	}
+}
+
+func TestNextKeepsLocation(t *testing.T) {
+	var loc = time.FixedZone("UTC+2", 2*60*60)
+	s, err := Parse("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var next = s.Next(time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)) // 03:00 in loc
+	if !next.Equal(time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("utc: got %v", next)
+	}
+	next = s.Next(time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC).In(loc))
+	if !next.Equal(time.Date(2026, 10, 19, 3, 0, 0, 0, loc)) || next.Location() != loc {
+		t.Errorf("utc+2: got %v", next)
+	}
+}
@@ -65,8 +65,20 @@ func GetFile(id snowflakes.ID) (io.ReadCloser, error) {
 	return f, nil
 }
 
+// GetPath returns the path of the file id, for callers that need to seek in it
+func GetPath(id snowflakes.ID) (string, error) {
+	var path = fromIdToPath(id)
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", ErrNoExists
+	}
+	return path, err
+}
+
 // does no attempt at sanitizing name
-func NewFile(name string, expiresIn time.Duration) (io.WriteCloser, snowflakes.ID, error) {
+//
+// the file is only made available by Close, Abort discards it
+func NewFile(name string, expiresIn time.Duration) (*Writer, snowflakes.ID, error) {
 	id := downloadNode.NewID()
 
 	err := os.MkdirAll(globals.DownloadFolder.WarnGet(), 0666)
@@ -74,12 +86,12 @@ func NewFile(name string, expiresIn time.Duration) (io.WriteCloser, snowflakes.I
 		fmt.Printf("Unable to create download directory: %s\n", err.Error())
 		return nil, "", err
 	}
-	f, err := os.Create(fromIdToPath(id))
+	f, err := os.Create(fromIdtoTempPath(id)) // renamed once complete, see Close
 	if err != nil {
 		return nil, "", err
 	}
 
-	var wr = &writer{
+	var wr = &Writer{
 		filaname:        name,
 		id:              id,
 		expirationStamp: time.Now().Add(expiresIn).Sub(snowflakes.GetEpoch()).Milliseconds(),
@@ -91,7 +103,7 @@ func NewFile(name string, expiresIn time.Duration) (io.WriteCloser, snowflakes.I
 	return wr, id, nil
 }
 
-type writer struct {
+type Writer struct {
 	filaname        string
 	id              snowflakes.ID
 	expirationStamp int64
@@ -100,16 +112,14 @@ type writer struct {
 	f               *os.File
 }
 
-func (wr *writer) Write(buf []byte) (int, error) {
+func (wr *Writer) Write(buf []byte) (int, error) {
 	n, err := wr.f.Write(buf)
 	wr.count += int64(n)
 	wr.hasher.Write(buf[:n])
 	return n, err
 }
 
-func (wr *writer) Close() error {
-	wr.f.Close()
-
+func (wr *Writer) Close() error {
 	var info = &Info{
 		Name:            wr.filaname,
 		Size:            wr.count,
@@ -120,10 +130,10 @@ func (wr *writer) Close() error {
 	i, err := wr.f.Stat()
 	if err != nil {
 		fmt.Printf("err: %v", err)
-	}
-	if i.Size() != info.Size {
+	} else if i.Size() != info.Size {
 		fmt.Printf("[???] sizes don't match")
 	}
+	wr.f.Close()
 
 	f, err := os.Create(fromIdtoPathInfo(wr.id))
 	if err != nil {
@@ -138,3 +148,9 @@ func (wr *writer) Close() error {
 
 	return os.Rename(fromIdtoTempPath(wr.id), fromIdToPath(wr.id))
 }
+
+// Abort deletes the incomplete file instead of making it available
+func (wr *Writer) Abort() error {
+	wr.f.Close()
+	return os.Remove(fromIdtoTempPath(wr.id))
+}
@@ -71,6 +71,14 @@ func (c ConfigKey[T]) WarnGet() T {
 	return v
 }
 
+// Get returns the default value of c if it is absent from config
+func (c ConfigKey[T]) Get() T {
+	if Config == nil {
+		return c.Default
+	}
+	return config.Key[T](c).Get(Config)
+}
+
 func (c ConfigTimeKey) WarnGet() time.Time {
 	v, err := config.TimeKey(c).GetErr(Config)
 	if err != nil {
@@ -88,6 +96,19 @@ var (
 	Time           = ConfigTimeKey{"epoch", time.Now()}
 	AssetsFolder   = ConfigKey[string]{"assets-folder", "/Users/temp/MineOs/assets/"}
 	OfflineMode    = ConfigKey[bool]{"offline-mode", false}
+	PortPoolStart  = ConfigKey[int]{"port-pool-start", 25565}
+	PortPoolEnd    = ConfigKey[int]{"port-pool-end", 25664}
+	ScrollbackSize = ConfigKey[int]{"scrollback-size", 1000}
+	// in seconds, 0 disables metrics
+	MetricsInterval = ConfigKey[int]{"metrics-interval", 5}
+	// in bytes, size after which the console log of a room is rotated
+	ConsoleLogMaxSize = ConfigKey[int64]{"console-log-max-size", 10 << 20}
+	// json list of java executables registered by admins
+	JavaRuntimesFile = ConfigKey[string]{"java-runtimes-file", "/Users/temp/MineOs/java.json"}
+	// cgroup v2 under which a cgroup is created for each server with resource limits
+	CgroupRoot = ConfigKey[string]{"cgroup-root", "/sys/fs/cgroup/mineos"}
+	// archive format of backups that do not choose one (zip, tar.gz or tar.zst)
+	BackupFormat = ConfigKey[string]{"backup-format", "zip"}
 )
 
 type MultiError []error
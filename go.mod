@@ -1,6 +1,6 @@
 module mineOS
 
-go 1.18
+go 1.22
 
 require (
 	github.com/Amqp-prtcl/routes v0.0.0-20220907115747-c9c4106a5870
@@ -11,3 +11,5 @@ require (
 require github.com/Amqp-prtcl/jwt v0.0.0-20220323135600-daff92ed18aa
 
 require github.com/Amqp-prtcl/config v0.0.0-20220926165132-9a93c2c1ac61
+
+require github.com/klauspost/compress v1.18.0
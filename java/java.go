@@ -0,0 +1,284 @@
+package java
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mineOS/globals"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// openjdk version "17.0.8" 2023-07-18
+	// java version "1.8.0_382"
+	versionReg = regexp.MustCompile(`version "([^"]+)"`)
+
+	ErrNoRuntime       = fmt.Errorf("no java runtime found")
+	ErrNoCompatible    = fmt.Errorf("no compatible java runtime")
+	ErrInvalidRuntime  = fmt.Errorf("invalid java runtime")
+	ErrRuntimeNotFound = fmt.Errorf("java runtime not registered")
+
+	runtimes   = []*Runtime{}
+	runtimesmu sync.RWMutex
+)
+
+const probeTimeout = 30 * time.Second
+
+type Runtime struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Major   int    `json:"major"`
+	// true if registered by an admin, false if discovered
+	Registered bool `json:"registered"`
+}
+
+// Setup discovers installed runtimes and probes the ones registered in file
+// (if file is empty, it is fetched from config)
+//
+// returns ErrNoRuntime if no runtime could be found at all
+func Setup(file string) error {
+	registered, err := loadRegistered(file)
+	if err != nil {
+		return err
+	}
+	var found = []*Runtime{}
+	var seen = map[string]bool{}
+	for _, path := range registered {
+		rt, err := Probe(path)
+		if err != nil {
+			fmt.Printf("[ERR] registered java runtime %v is unusable: %v\n", path, err)
+			continue
+		}
+		rt.Registered = true
+		found = append(found, rt)
+		seen[rt.Path] = true
+	}
+	for _, path := range candidates() {
+		rt, err := Probe(path)
+		if err != nil || seen[rt.Path] {
+			continue
+		}
+		found = append(found, rt)
+		seen[rt.Path] = true
+	}
+	runtimesmu.Lock()
+	runtimes = found
+	sortRuntimes()
+	runtimesmu.Unlock()
+	if len(found) == 0 {
+		return ErrNoRuntime
+	}
+	for _, rt := range found {
+		fmt.Printf("found java %v (%v)\n", rt.Version, rt.Path)
+	}
+	return nil
+}
+
+// candidates returns the java executables found in PATH, JAVA_HOME and usual install folders
+func candidates() []string {
+	var paths = []string{}
+	if path, err := exec.LookPath("java"); err == nil {
+		paths = append(paths, path)
+	}
+	if home := os.Getenv("JAVA_HOME"); home != "" {
+		paths = append(paths, filepath.Join(home, "bin", "java"))
+	}
+	var patterns = []string{
+		"/usr/lib/jvm/*/bin/java",
+		"/usr/lib64/jvm/*/bin/java",
+		"/usr/java/*/bin/java",
+		"/opt/java/*/bin/java",
+		"/opt/*jdk*/bin/java",
+		"/Library/Java/JavaVirtualMachines/*/Contents/Home/bin/java",
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		patterns = append(patterns, filepath.Join(home, ".sdkman/candidates/java/*/bin/java"))
+	}
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		paths = append(paths, matches...)
+	}
+	return paths
+}
+
+// Probe runs path -version to get its version, path is resolved to an absolute path without symlinks
+func Probe(path string) (*Runtime, error) {
+	path, err := exec.LookPath(path)
+	if err != nil {
+		return nil, err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "-version").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRuntime, err)
+	}
+	m := versionReg.FindSubmatch(out)
+	if m == nil {
+		return nil, fmt.Errorf("%w: unknown version output %q", ErrInvalidRuntime, out)
+	}
+	var version = string(m[1])
+	major, err := ParseMajor(version)
+	if err != nil {
+		return nil, err
+	}
+	return &Runtime{Path: path, Version: version, Major: major}, nil
+}
+
+// ParseMajor returns the major version of a java version string (ex: 8 for "1.8.0_382", 17 for "17.0.8")
+func ParseMajor(version string) (int, error) {
+	var parts = strings.FieldsFunc(version, func(r rune) bool {
+		return r == '.' || r == '_' || r == '-' || r == '+'
+	})
+	if len(parts) == 0 {
+		return 0, fmt.Errorf("%w: invalid version %q", ErrInvalidRuntime, version)
+	}
+	var i = 0
+	if parts[0] == "1" && len(parts) > 1 {
+		i = 1
+	}
+	major, err := strconv.Atoi(parts[i])
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid version %q", ErrInvalidRuntime, version)
+	}
+	return major, nil
+}
+
+// List returns all known runtimes, by ascending major version
+func List() []Runtime {
+	runtimesmu.RLock()
+	defer runtimesmu.RUnlock()
+	var l = []Runtime{}
+	for _, rt := range runtimes {
+		l = append(l, *rt)
+	}
+	return l
+}
+
+// Select returns the runtime with the lowest major version that is at least required
+// (0 means unknown: no runtime is guessed and ErrNoCompatible is returned)
+//
+// the lowest compatible version is preferred as old servers and mods often break on recent java versions
+func Select(required int) (Runtime, error) {
+	runtimesmu.RLock()
+	defer runtimesmu.RUnlock()
+	if len(runtimes) == 0 {
+		return Runtime{}, ErrNoRuntime
+	}
+	if required == 0 {
+		return Runtime{}, fmt.Errorf("%w: the required java version is unknown", ErrNoCompatible)
+	}
+	for _, rt := range runtimes {
+		if rt.Major >= required {
+			return *rt, nil
+		}
+	}
+	return Runtime{}, fmt.Errorf("%w: java %v or newer is required", ErrNoCompatible, required)
+}
+
+// Register probes path and adds it to the registered runtimes
+func Register(path string) (Runtime, error) {
+	rt, err := Probe(path)
+	if err != nil {
+		return Runtime{}, err
+	}
+	rt.Registered = true
+	runtimesmu.Lock()
+	defer runtimesmu.Unlock()
+	var found bool
+	for i := range runtimes {
+		if runtimes[i].Path == rt.Path {
+			runtimes[i] = rt
+			found = true
+		}
+	}
+	if !found {
+		runtimes = append(runtimes, rt)
+	}
+	sortRuntimes() // the version of a replaced runtime may have changed
+	return *rt, saveRegistered("")
+}
+
+// Unregister removes a registered runtime (discovered runtimes cannot be removed)
+func Unregister(path string) error {
+	runtimesmu.Lock()
+	defer runtimesmu.Unlock()
+	for i, rt := range runtimes {
+		if rt.Path == path && rt.Registered {
+			runtimes = append(runtimes[:i], runtimes[i+1:]...)
+			return saveRegistered("")
+		}
+	}
+	return ErrRuntimeNotFound
+}
+
+// does NOT lock mutexes
+func sortRuntimes() {
+	sort.SliceStable(runtimes, func(i, j int) bool {
+		return runtimes[i].Major < runtimes[j].Major
+	})
+}
+
+// if file is empty, it is fetched from config
+func loadRegistered(file string) ([]string, error) {
+	if file == "" {
+		file = globals.JavaRuntimesFile.Get()
+	}
+	var paths = []string{}
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return paths, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&paths)
+	return paths, err
+}
+
+// does NOT lock mutexes
+func saveRegistered(file string) error {
+	if file == "" {
+		file = globals.JavaRuntimesFile.Get()
+	}
+	var paths = []string{}
+	for _, rt := range runtimes {
+		if rt.Registered {
+			paths = append(paths, rt.Path)
+		}
+	}
+	// written next to the file then renamed, so that a crash cannot leave a truncated file
+	f, err := os.Create(file + ".tmp")
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(paths)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(file + ".tmp")
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
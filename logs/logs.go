@@ -0,0 +1,153 @@
+package logs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoExists    = errors.New("log file not found")
+	ErrInvalidName = errors.New("invalid log file name")
+)
+
+type FileInfo struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	Modified   time.Time `json:"modified"`
+	Compressed bool      `json:"compressed"`
+}
+
+// List returns the log files of dir sorted from newest to oldest
+//
+// if dir does not exist, List returns an empty list
+func List(dir string) ([]*FileInfo, error) {
+	var files = []*FileInfo{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return files, nil
+		}
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !isLogFile(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &FileInfo{
+			Name:       e.Name(),
+			Size:       info.Size(),
+			Modified:   info.ModTime(),
+			Compressed: strings.HasSuffix(e.Name(), ".gz"),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Modified.After(files[j].Modified)
+	})
+	return files, nil
+}
+
+func isLogFile(name string) bool {
+	return strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")
+}
+
+// Open returns the content of log file name in dir, gzipped files are transparently decompressed
+//
+// it is the caller's responsibility to call Close
+func Open(dir string, name string) (io.ReadCloser, error) {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") || !isLogFile(name) {
+		return nil, ErrInvalidName
+	}
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoExists
+		}
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: gz, f: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+// Query selects part of a log file; zero values select everything
+//
+// Offset is applied first, then From and To, then Tail
+type Query struct {
+	Offset int64 // number of (decompressed) bytes to skip
+	From   int   // first line (starting at 1)
+	To     int   // last line (included)
+	Tail   int   // only keeps the last Tail lines
+}
+
+// Copy writes the lines of r selected by q to w
+func Copy(w io.Writer, r io.Reader, q Query) error {
+	if q.Offset > 0 {
+		_, err := io.CopyN(io.Discard, r, q.Offset)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if q.From <= 0 && q.To <= 0 && q.Tail <= 0 {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	var tail = []string{}
+	var br = bufio.NewReader(r)
+	for n := 1; q.To <= 0 || n <= q.To; n++ {
+		line, err := br.ReadString('\n')
+		if line != "" && n >= q.From {
+			if q.Tail > 0 {
+				tail = append(tail, line)
+				if len(tail) > q.Tail {
+					tail = tail[1:]
+				}
+			} else if _, werr := io.WriteString(w, line); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, line := range tail {
+		_, err := io.WriteString(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
-	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mineOS/archive"
 	"mineOS/downloads"
 	"mineOS/emails"
 	"mineOS/globals"
+	"mineOS/java"
+	"mineOS/logs"
 	"mineOS/manager"
+	"mineOS/mojang"
+	"mineOS/properties"
 	"mineOS/rooms"
 	"mineOS/servers"
 	"mineOS/tokens"
@@ -17,7 +24,6 @@ import (
 	"mineOS/versions"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strconv"
@@ -93,25 +99,15 @@ func init() {
 		panic(err)
 	}
 
-	//load servers -> load manager
-	err = manager.M.LoadRooms("")
-	if err != nil {
-		fmt.Printf("[ERR] failed to load servers profile file.\n")
-		panic(err)
-	}
-
-	// check for java
-	fmt.Printf("checking for java... ")
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-	err = exec.CommandContext(ctx, "java", "-version").Run()
-	cancel()
+	// look for java runtimes (before loading servers as scheduled tasks may start them)
+	fmt.Printf("looking for java runtimes...\n")
+	err = java.Setup("")
 	if err != nil {
-		fmt.Printf("\n[ERR] Java not Found\n")
+		fmt.Printf("[ERR] Java not Found\n")
 		panic(err)
 	}
-	fmt.Printf("java found\n")
 
-	//fetching minecraft versions
+	//fetching minecraft versions (before loading servers, their java version comes from the manifests)
 
 	fmt.Printf("fetching minecraft versions...\n")
 	err = versions.Setup("", globals.OfflineMode.WarnGet())
@@ -120,6 +116,13 @@ func init() {
 		panic(err)
 	}
 
+	//load servers -> load manager
+	err = manager.M.LoadRooms("")
+	if err != nil {
+		fmt.Printf("[ERR] failed to load servers profile file.\n")
+		panic(err)
+	}
+
 	fmt.Printf("starting app...\n")
 }
 
@@ -139,6 +142,7 @@ func main() {
 		idRegex      = `[0-9]+`
 		srvTypeRegex = `[A-Z]+`
 		vrsIDRegex   = `.+`
+		fileRegex    = `[^/]+`
 	)
 
 	router := routes.NewRouter(onAuth)
@@ -156,6 +160,7 @@ func main() {
 	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/servers/(`+idRegex+`)/server-properties/?$`, Auth, getServerProperties))
 	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/servers/(`+idRegex+`)/server-properties/?$`, Auth, setServerProperties))
 	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/servers/(`+idRegex+`)/zip/?$`, Auth, zipServerHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/servers/(`+idRegex+`)/archive/?$`, Auth, getServerArchiveHandler))
 	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/assets/(.+)/?$`, Auth, assetsHandler))
 	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/download/(`+idRegex+`)/?$`, Auth, getDownload))
 	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/download/(`+idRegex+`)/info/?$`, Auth, getDownloadInfo))
@@ -173,7 +178,60 @@ func main() {
 	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/?$`, Auth, getServerListHandler))
 	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/?$`, Auth, getServerInfoHandler))
 	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/emails/?$`, Auth, postServerEmailHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/jvm/?$`, Auth, getServerJVMHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/jvm/?$`, Auth, postServerJVMHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/restart-policy/?$`, Auth, getServerRestartPolicyHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/restart-policy/?$`, Auth, postServerRestartPolicyHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/idle-shutdown/?$`, Auth, getServerIdleShutdownHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/idle-shutdown/?$`, Auth, postServerIdleShutdownHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/stop-timeouts/?$`, Auth, getServerStopTimeoutsHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/stop-timeouts/?$`, Auth, postServerStopTimeoutsHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/java/?$`, Auth, getServerJavaHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/java/?$`, Auth, postServerJavaHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/limits/?$`, Auth, getServerLimitsHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/limits/?$`, Auth, postServerLimitsHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/properties/?$`, Auth, getServerPropertiesHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPatch, `^/api/servers/(`+idRegex+`)/properties/?$`, Auth, patchServerPropertiesHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/backup/?$`, Auth, postServerBackupHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/backup-rules/?$`, Auth, getServerBackupRulesHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/backup-rules/?$`, Auth, postServerBackupRulesHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/restore/?$`, Auth, postServerRestoreHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/restore/upload/?$`, Auth, postServerRestoreUploadHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/worlds/?$`, Auth, getServerWorldsHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/worlds/?$`, Auth, postServerWorldHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodDelete, `^/api/servers/(`+idRegex+`)/worlds/(`+fileRegex+`)/?$`, Auth, deleteServerWorldHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/worlds/(`+fileRegex+`)/reset/?$`, Auth, resetServerWorldHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/worlds/(`+fileRegex+`)/activate/?$`, Auth, activateServerWorldHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/console/?$`, Auth, getServerConsoleHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/logs/?$`, Auth, getServerLogsHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/logs/bundle/?$`, Auth, postServerLogsBundleHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/logs/(`+fileRegex+`)$`, Auth, getServerLogFileHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/players/?$`, Auth, getServerPlayersHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/command/?$`, Auth, postServerCommandHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/schedules/?$`, Auth, getServerSchedulesHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/schedules/?$`, Auth, postServerScheduleHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/schedules/(`+idRegex+`)/?$`, Auth, getServerScheduleHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/schedules/(`+idRegex+`)/?$`, Auth, updateServerScheduleHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodDelete, `^/api/servers/(`+idRegex+`)/schedules/(`+idRegex+`)/?$`, Auth, deleteServerScheduleHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/whitelist/?$`, Auth, getServerAccessListHandler(rooms.Whitelist)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/whitelist/?$`, Auth, postServerAccessHandler(rooms.Whitelist)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodDelete, `^/api/servers/(`+idRegex+`)/whitelist/(`+fileRegex+`)/?$`, Auth, deleteServerAccessHandler(rooms.Whitelist)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/ops/?$`, Auth, getServerAccessListHandler(rooms.Ops)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/ops/?$`, Auth, postServerAccessHandler(rooms.Ops)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodDelete, `^/api/servers/(`+idRegex+`)/ops/(`+fileRegex+`)/?$`, Auth, deleteServerAccessHandler(rooms.Ops)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/bans/players/?$`, Auth, getServerAccessListHandler(rooms.BannedPlayers)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/bans/players/?$`, Auth, postServerAccessHandler(rooms.BannedPlayers)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodDelete, `^/api/servers/(`+idRegex+`)/bans/players/(`+fileRegex+`)/?$`, Auth, deleteServerAccessHandler(rooms.BannedPlayers)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/servers/(`+idRegex+`)/bans/ips/?$`, Auth, getServerAccessListHandler(rooms.BannedIPs)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/(`+idRegex+`)/bans/ips/?$`, Auth, postServerAccessHandler(rooms.BannedIPs)))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodDelete, `^/api/servers/(`+idRegex+`)/bans/ips/(`+fileRegex+`)/?$`, Auth, deleteServerAccessHandler(rooms.BannedIPs)))
 	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/servers/new/?$`, Auth, postNewServerHandler))
+	//jvm
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/jvm/presets/?$`, Auth, getJVMPresetsHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/properties/schema/?$`, Auth, getPropertiesSchemaHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/api/java/?$`, Auth, getJavaRuntimesHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodPost, `^/api/java/?$`, Auth, postJavaRuntimeHandler))
+	router.MustAddRoute(routes.MustNewRoute(http.MethodDelete, `^/api/java/?$`, Auth, deleteJavaRuntimeHandler))
 
 	//WEBSOCKETS
 	router.MustAddRoute(routes.MustNewRoute(http.MethodGet, `^/servers/ws/?$`, Auth, serverListWebsocketHandler))
@@ -246,10 +304,12 @@ func startServerHandler(w http.ResponseWriter, r *http.Request, e interface{}, m
 	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 	room, ok := manager.M.GetRoombyID(id)
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 	err = room.Start()
 	if err != nil {
@@ -257,7 +317,12 @@ func startServerHandler(w http.ResponseWriter, r *http.Request, e interface{}, m
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, rooms.ErrPortInUse) || errors.Is(err, java.ErrNoCompatible) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -266,18 +331,22 @@ func stopServerHandler(w http.ResponseWriter, r *http.Request, e interface{}, ma
 	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 	room, ok := manager.M.GetRoombyID(id)
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
+		return
 	}
-	err = room.Stop()
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	err = room.Stop(force)
 	if err != nil {
 		if err == servers.ErrNotStarted {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -286,10 +355,12 @@ func getServerProperties(w http.ResponseWriter, r *http.Request, e interface{},
 	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 	room, ok := manager.M.GetRoombyID(id)
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 	f, err := os.Open(room.Profile.GetServerPropertiesFile())
 	if err != nil {
@@ -308,38 +379,97 @@ func setServerProperties(w http.ResponseWriter, r *http.Request, e interface{},
 	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 	room, ok := manager.M.GetRoombyID(id)
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 	if r.ContentLength == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	f, err := os.Create(room.Profile.GetServerPropertiesFile())
+	err = room.ReplaceProperties(r.Body)
+	r.Body.Close()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writePropertiesError(w, err)
 		return
 	}
-	defer f.Close()
-	defer r.Body.Close()
-	i, err := io.Copy(f, r.Body)
-	if err != nil && i == 0 {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writePropertiesError(w http.ResponseWriter, err error) {
+	var errs rooms.PropertiesError
+	if errors.As(err, &errs) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(struct {
+			Errors rooms.PropertiesError `json:"errors"`
+		}{errs})
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+func getServerPropertiesHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	props, err := room.GetProperties()
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	json.NewEncoder(w).Encode(props)
+}
+
+func patchServerPropertiesHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var changes = map[string]interface{}{}
+	err = json.NewDecoder(r.Body).Decode(&changes)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	patch, err := room.PatchProperties(changes)
+	if err != nil {
+		writePropertiesError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(patch)
+}
+
+func getPropertiesSchemaHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	json.NewEncoder(w).Encode(properties.Known)
 }
 
 func zipServerHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
 	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 	room, ok := manager.M.GetRoombyID(id)
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 	id, err = room.Zip()
 	if err != nil {
@@ -355,6 +485,82 @@ func zipServerHandler(w http.ResponseWriter, r *http.Request, e interface{}, mat
 	}{id})
 }
 
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// time given to the client to accept each write of a streamed archive
+const streamWriteTimeout = time.Minute
+
+// responseDeadlineWriter gives each write to the response streamWriteTimeout to complete
+type responseDeadlineWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (d *responseDeadlineWriter) Write(p []byte) (int, error) {
+	err := d.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return 0, err
+	}
+	return d.w.Write(p)
+}
+
+// getServerArchiveHandler streams an archive of the server as the response, its SHA-256 is sent as a trailer
+func getServerArchiveHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	format, err := rooms.BackupFormat(archive.Format(r.URL.Query().Get("format")))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var name = fmt.Sprintf("server-%s-%v%s", room.Profile.Name, time.Now().UnixMilli(), format.Extension())
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(name))
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Trailer", "Content-Digest")
+
+	var rc = http.NewResponseController(w)
+	defer rc.SetWriteDeadline(time.Time{}) // the connection can be reused
+	var hash = sha256.New()
+	var out = &countingWriter{w: io.MultiWriter(&responseDeadlineWriter{w, rc}, hash)}
+	err = room.StreamArchive(out, format)
+	if err != nil && out.n == 0 { // nothing was sent yet, the status can still tell what went wrong
+		w.Header().Del("Content-Disposition")
+		w.Header().Del("Trailer")
+		w.Header().Del("Content-Type")
+		switch {
+		case errors.Is(err, rooms.ErrBackupRunning), errors.Is(err, rooms.ErrServerBusy), errors.Is(err, servers.ErrNotClosed):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	if err != nil {
+		// the response is cut without its last chunk so that the client sees the archive is incomplete
+		fmt.Printf("streaming archive of server %v failed after %v bytes: %v\n", room.Profile.ID, out.n, err)
+		panic(http.ErrAbortHandler)
+	}
+	w.Header().Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(hash.Sum(nil))+":")
+}
+
 func assetsHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
 	if strings.Contains(matches[0], "..") {
 		w.WriteHeader(http.StatusBadRequest)
@@ -367,6 +573,7 @@ func getDownload(w http.ResponseWriter, r *http.Request, e interface{}, matches
 	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
 	info, err := downloads.GetInfo(id)
@@ -399,6 +606,7 @@ func getDownloadInfo(w http.ResponseWriter, r *http.Request, e interface{}, matc
 	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 	info, err := downloads.GetInfo(id)
 	if err != nil {
@@ -521,52 +729,87 @@ func postServerEmailHandler(w http.ResponseWriter, r *http.Request, e interface{
 	room.AddEmail(mails...)
 }
 
-func postNewServerHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
-	var info = struct {
-		Name    string              `json:"name"`
-		Emails  []string            `json:"emails"`
-		SrvType versions.ServerType `json:"server-type"`
-		VrsID   string              `json:"version-id"`
-	}{}
-	err := json.NewDecoder(r.Body).Decode(&info)
+func getServerJVMHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(room.GetJVMConfig())
+}
+
+func postServerJVMHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var cfg = &servers.JVMConfig{}
+	err = json.NewDecoder(r.Body).Decode(cfg)
 	r.Body.Close()
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if info.Name == "" {
+	err = room.SetJVMConfig(cfg)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	prof, err := rooms.GenerateRoom(info.Name, info.SrvType, info.VrsID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getServerRestartPolicyHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
-		fmt.Println(2, err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	prof.Emails = append([]string{}, info.Emails...)
-	ok := manager.M.NewRoom(prof)
+	room, ok := manager.M.GetRoombyID(id)
 	if !ok {
-		w.WriteHeader(http.StatusConflict)
-		fmt.Printf("??? failed to add new room to roomManager: ID already exist ???")
+		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	json.NewEncoder(w).Encode(struct {
-		ID snowflakes.ID `json:"id"`
-	}{ID: prof.ID})
+	json.NewEncoder(w).Encode(room.Profile.Restart)
 }
 
-func serverListWebsocketHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+func postServerRestartPolicyHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
-		log.Printf("failed to upgrade connection: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	manager.M.AddConn(conn)
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var policy = &rooms.RestartPolicy{}
+	err = json.NewDecoder(r.Body).Decode(policy)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = room.SetRestartPolicy(policy)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func serverWebsocketHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+func getServerIdleShutdownHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
 	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -577,11 +820,939 @@ func serverWebsocketHandler(w http.ResponseWriter, r *http.Request, e interface{
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	conn, err := upgrader.Upgrade(w, r, nil)
+	json.NewEncoder(w).Encode(room.GetIdleShutdown())
+}
+
+func postServerIdleShutdownHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var idle = &rooms.IdleShutdown{}
+	err = json.NewDecoder(r.Body).Decode(idle)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = room.SetIdleShutdown(idle)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getServerStopTimeoutsHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(room.GetStopTimeouts())
+}
+
+func postServerStopTimeoutsHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var timeouts = &servers.StopTimeouts{}
+	err = json.NewDecoder(r.Body).Decode(timeouts)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = room.SetStopTimeouts(timeouts)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getServerLimitsHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	room.AddConn(conn)
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Limits *servers.Limits      `json:"limits"`
+		Cgroup string               `json:"cgroup"`
+		Usage  *servers.CgroupUsage `json:"usage"`
+		Error  string               `json:"error,omitempty"`
+	}{room.GetLimits(), room.Srv.Cgroup(), room.Srv.GetCgroupUsage(), room.Srv.CgroupError()})
+}
+
+func postServerLimitsHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var limits = &servers.Limits{}
+	err = json.NewDecoder(r.Body).Decode(limits)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = room.SetLimits(limits)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getServerConsoleHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	level, ok := parseLogLevel(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(room.GetScrollback(level))
+}
+
+func getServerLogsHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	files, err := logs.List(room.Profile.GetLogsFolder())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(files)
+}
+
+func getServerLogFileHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var q = logs.Query{}
+	var values = r.URL.Query()
+	for key, v := range map[string]*int{"from": &q.From, "to": &q.To, "tail": &q.Tail} {
+		if values.Get(key) == "" {
+			continue
+		}
+		*v, err = strconv.Atoi(values.Get(key))
+		if err != nil || *v < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if values.Get("offset") != "" {
+		q.Offset, err = strconv.ParseInt(values.Get("offset"), 10, 64)
+		if err != nil || q.Offset < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	f, err := logs.Open(room.Profile.GetLogsFolder(), matches[1])
+	if err != nil {
+		switch err {
+		case logs.ErrInvalidName:
+			w.WriteHeader(http.StatusBadRequest)
+		case logs.ErrNoExists:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	err = logs.Copy(w, f, q)
+	if err != nil {
+		fmt.Printf("error sending log file %v: %v\n", matches[1], err)
+	}
+}
+
+func postServerLogsBundleHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	id, err = room.ZipLogs()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Id snowflakes.ID `json:"download-id"`
+	}{id})
+}
+
+func getServerPlayersHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(room.Srv.GetPlayers())
+}
+
+func postServerCommandHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var body = struct {
+		Command string `json:"command"`
+	}{}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	r.Body.Close()
+	if err != nil || body.Command == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	out, viaRcon, err := room.SendCommand(body.Command)
+	if err != nil {
+		if err == servers.ErrNotStarted {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Output string `json:"output"`
+		Rcon   bool   `json:"rcon"`
+	}{out, viaRcon})
+}
+
+func getJVMPresetsHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	json.NewEncoder(w).Encode(servers.Presets)
+}
+
+func postNewServerHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	var info = struct {
+		Name    string              `json:"name"`
+		Emails  []string            `json:"emails"`
+		SrvType versions.ServerType `json:"server-type"`
+		VrsID   string              `json:"version-id"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&info)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if info.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	prof, err := rooms.GenerateRoom(info.Name, info.SrvType, info.VrsID)
+	if err != nil {
+		fmt.Println(2, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	prof.Emails = append([]string{}, info.Emails...)
+	err = manager.M.NewRoom(prof)
+	if err != nil {
+		if err == manager.ErrNoPortAvailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		fmt.Printf("??? failed to add new room to roomManager: ID already exist ???")
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		ID snowflakes.ID `json:"id"`
+	}{ID: prof.ID})
+}
+
+func serverListWebsocketHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("failed to upgrade connection: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	manager.M.AddConn(conn)
+}
+
+func serverWebsocketHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	level, ok := parseLogLevel(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	room.AddConn(conn, level)
+}
+
+// parses the optional "level" query parameter
+func parseLogLevel(r *http.Request) (servers.LogLevel, bool) {
+	var str = r.URL.Query().Get("level")
+	if str == "" {
+		return "", true
+	}
+	return servers.ToLogLevel(str)
+}
+
+func getServerSchedulesHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(room.GetSchedules())
+}
+
+func postServerScheduleHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var sched = &rooms.Schedule{}
+	err = json.NewDecoder(r.Body).Decode(sched)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	id, err = room.AddSchedule(sched)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Id snowflakes.ID `json:"id"`
+	}{id})
+}
+
+func getServerScheduleHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	schedID, err := snowflakes.ParseID(matches[1])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	sched, err := room.GetSchedule(schedID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(sched)
+}
+
+func updateServerScheduleHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	schedID, err := snowflakes.ParseID(matches[1])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var sched = &rooms.Schedule{}
+	err = json.NewDecoder(r.Body).Decode(sched)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = room.UpdateSchedule(schedID, sched)
+	if errors.Is(err, rooms.ErrScheduleNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deleteServerScheduleHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	schedID, err := snowflakes.ParseID(matches[1])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	err = room.RemoveSchedule(schedID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getServerAccessListHandler(list rooms.AccessList) routes.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+		id, err := snowflakes.ParseID(matches[0])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		room, ok := manager.M.GetRoombyID(id)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		entries, err := room.GetAccessList(list)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(entries)
+	}
+}
+
+func postServerAccessHandler(list rooms.AccessList) routes.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+		id, err := snowflakes.ParseID(matches[0])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		room, ok := manager.M.GetRoombyID(id)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var entry = rooms.AccessEntry{}
+		err = json.NewDecoder(r.Body).Decode(&entry)
+		r.Body.Close()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		out, live, err := room.AddAccess(list, entry)
+		writeAccessResult(w, out, live, err)
+	}
+}
+
+func deleteServerAccessHandler(list rooms.AccessList) routes.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+		id, err := snowflakes.ParseID(matches[0])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		room, ok := manager.M.GetRoombyID(id)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		out, live, err := room.RemoveAccess(list, matches[1])
+		writeAccessResult(w, out, live, err)
+	}
+}
+
+func writeAccessResult(w http.ResponseWriter, out string, live bool, err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, rooms.ErrInvalidEntry):
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	case errors.Is(err, rooms.ErrEntryNotFound), errors.Is(err, mojang.ErrPlayerNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case errors.Is(err, rooms.ErrServerBusy), errors.Is(err, servers.ErrNotStarted):
+		w.WriteHeader(http.StatusConflict)
+		return
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Live   bool   `json:"live"`
+		Output string `json:"output"`
+	}{live, out})
+}
+
+func postServerBackupHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	err = room.StartBackup(archive.Format(r.URL.Query().Get("format")))
+	if errors.Is(err, archive.ErrUnknownFormat) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, rooms.ErrBackupRunning) || errors.Is(err, rooms.ErrServerBusy) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func getServerBackupRulesHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Rules    []string `json:"rules"`
+		Defaults []string `json:"defaults"`
+	}{room.GetBackupRules(), rooms.DefaultBackupRules()})
+}
+
+func postServerBackupRulesHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var body struct {
+		Rules []string `json:"rules"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = room.SetBackupRules(body.Rules)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func postServerRestoreHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var body = struct {
+		DownloadID snowflakes.ID `json:"download-id"`
+		Paths      []string      `json:"paths"`
+	}{}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	r.Body.Close()
+	if err != nil || body.DownloadID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res, err := room.RestoreDownload(body.DownloadID, body.Paths)
+	writeRestoreResult(w, res, err)
+}
+
+func postServerRestoreUploadHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !room.Srv.State.IsClosed() { // checked again by Restore, avoids uploading for nothing
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	res, err := room.RestoreUpload(r.Body, r.URL.Query()["path"])
+	r.Body.Close()
+	writeRestoreResult(w, res, err)
+}
+
+func writeRestoreResult(w http.ResponseWriter, res rooms.RestoreResult, err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, rooms.ErrInvalidBackup):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, downloads.ErrNoExists):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, servers.ErrNotClosed):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		fmt.Printf("restore failed: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	if err == nil || res.Snapshot != "" {
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func getServerWorldsHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	worlds, err := room.ListWorlds()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(worlds)
+}
+
+func postServerWorldHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	replace, _ := strconv.ParseBool(r.URL.Query().Get("replace"))
+	name, backup, err := room.UploadWorld(r.Body, r.URL.Query().Get("name"), replace)
+	r.Body.Close()
+	if err != nil {
+		writeWorldError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Name   string        `json:"name"`
+		Backup snowflakes.ID `json:"backup,omitempty"`
+	}{name, backup})
+}
+
+func deleteServerWorldHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	backup, err := room.DeleteWorld(matches[1])
+	if err != nil {
+		writeWorldError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Backup snowflakes.ID `json:"backup"`
+	}{backup})
+}
+
+func resetServerWorldHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var body = struct {
+		Seed *string `json:"seed"`
+	}{}
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			r.Body.Close()
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	r.Body.Close()
+	backup, err := room.ResetWorld(matches[1], body.Seed)
+	if err != nil {
+		writeWorldError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Backup snowflakes.ID `json:"backup,omitempty"`
+	}{backup})
+}
+
+func activateServerWorldHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	patch, err := room.SwitchWorld(matches[1])
+	if err != nil {
+		writeWorldError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(patch)
+}
+
+func writeWorldError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, rooms.ErrInvalidWorld), errors.Is(err, properties.ErrInvalidValue), errors.Is(err, rooms.ErrInvalidProperties):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, rooms.ErrWorldNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, rooms.ErrWorldExists), errors.Is(err, servers.ErrNotClosed):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		fmt.Printf("world operation failed: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func getJavaRuntimesHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	json.NewEncoder(w).Encode(java.List())
+}
+
+func postJavaRuntimeHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	var body = struct {
+		Path string `json:"path"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	r.Body.Close()
+	if err != nil || body.Path == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rt, err := java.Register(body.Path)
+	if err != nil {
+		if rt.Path == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// registered but the registry file could not be saved
+		fmt.Printf("failed to save java runtimes: %v\n", err)
+	}
+	json.NewEncoder(w).Encode(rt)
+}
+
+func deleteJavaRuntimeHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	var body = struct {
+		Path string `json:"path"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = java.Unregister(body.Path)
+	if err == java.ErrRuntimeNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getServerJavaHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var info = struct {
+		JavaPath string `json:"java-path"`
+		Required int    `json:"required"`
+		Runtime  string `json:"runtime"`
+		Error    string `json:"error,omitempty"`
+	}{
+		JavaPath: room.GetJavaPath(),
+		Required: room.Profile.RequiredJava(),
+	}
+	info.Runtime, err = room.GetJava()
+	if err != nil {
+		info.Error = err.Error()
+	}
+	json.NewEncoder(w).Encode(info)
+}
+
+func postServerJavaHandler(w http.ResponseWriter, r *http.Request, e interface{}, matches []string) {
+	id, err := snowflakes.ParseID(matches[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	room, ok := manager.M.GetRoombyID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var body = struct {
+		JavaPath string `json:"java-path"`
+	}{}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = room.SetJavaPath(body.JavaPath)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -2,7 +2,9 @@ package manager
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mineOS/globals"
 	"mineOS/rooms"
 	"mineOS/servers"
 	"mineOS/versions"
@@ -14,11 +16,15 @@ import (
 
 var (
 	M = NewManager()
+
+	ErrRoomExists      = fmt.Errorf("room already exists")
+	ErrNoPortAvailable = fmt.Errorf("no port available in pool")
 )
 
 type Manager struct {
 	Rooms   []*rooms.Room
 	roomsmu sync.RWMutex
+	savemu  sync.Mutex // profiles file
 
 	list   []*websocket.Conn
 	listmu sync.Mutex
@@ -45,18 +51,43 @@ func (m *Manager) LoadRooms(file string) error {
 		return err
 	}
 	for _, p := range profiles {
-		m.Rooms = append(m.Rooms, rooms.NewRoom(p, m.OnStateChange))
+		err = m.assignPorts(p)
+		if err != nil {
+			fmt.Printf("[ERR] failed to assign ports to server %v: %v\n", p.ID, err)
+		}
+		var room = rooms.NewRoom(p, m.OnStateChange)
+		room.OnProfileChange = m.onProfileChange
+		err = room.Reattach()
+		if err == nil {
+			fmt.Printf("reattached to running server %v\n", p.ID)
+		} else if !errors.Is(err, servers.ErrNotSupervised) {
+			fmt.Printf("[ERR] failed to reattach to server %v: %v\n", p.ID, err)
+		}
+		room.StartSchedules()
+		m.Rooms = append(m.Rooms, room)
 	}
 	return nil
 }
 
+// onProfileChange saves the profiles in the background (it can be called while roomsmu is held)
+func (m *Manager) onProfileChange() {
+	go func() {
+		err := m.SaveRooms("")
+		if err != nil {
+			fmt.Printf("[ERR] failed to save server profiles: %v\n", err)
+		}
+	}()
+}
+
 // if file is empty, it is fetched from config
 func (m *Manager) SaveRooms(file string) error {
+	m.savemu.Lock()
+	defer m.savemu.Unlock()
 	m.roomsmu.RLock()
 	defer m.roomsmu.RUnlock()
 	var a = []*rooms.RoomProfile{}
 	for _, room := range m.Rooms {
-		a = append(a, room.Profile) // there can be race condition with emails
+		a = append(a, room.SavedProfile())
 	}
 	return rooms.SaveProfiles(file, a)
 }
@@ -109,16 +140,76 @@ func (m *Manager) OnStateChange(srv *servers.Server) {
 	m.listmu.Unlock()
 }
 
-func (m *Manager) NewRoom(prof *rooms.RoomProfile) bool {
+func (m *Manager) NewRoom(prof *rooms.RoomProfile) error {
 	m.roomsmu.Lock()
 	defer m.roomsmu.Unlock()
 	for _, rm := range m.Rooms {
 		if rm.Profile.ID == prof.ID /*|| rm.Profile.Name == prof.Name*/ {
-			return false
+			return ErrRoomExists
+		}
+	}
+	err := m.assignPorts(prof)
+	if err != nil {
+		return err
+	}
+	var room = rooms.NewRoom(prof, m.OnStateChange)
+	room.OnProfileChange = m.onProfileChange
+	room.StartSchedules()
+	m.Rooms = append(m.Rooms, room)
+	return nil
+}
+
+// returns the ports assigned to all rooms except prof
+//
+// does NOT lock mutexes
+func (m *Manager) usedPorts(prof *rooms.RoomProfile) map[int]bool {
+	var used = map[int]bool{}
+	for _, rm := range m.Rooms {
+		if rm.Profile == prof || rm.Profile.Ports == nil {
+			continue
+		}
+		for _, port := range rm.Profile.Ports.List() {
+			used[port] = true
+		}
+	}
+	return used
+}
+
+// assignPorts keeps the ports of prof if they are in the pool and unique,
+// otherwise new ones are taken from the pool
+//
+// does NOT lock mutexes
+func (m *Manager) assignPorts(prof *rooms.RoomProfile) error {
+	var start, end = globals.PortPoolStart.Get(), globals.PortPoolEnd.Get()
+	var used = m.usedPorts(prof)
+	if prof.Ports != nil {
+		var ok = true
+		var seen = map[int]bool{}
+		for _, port := range prof.Ports.List() {
+			if port < start || port > end || used[port] || seen[port] {
+				ok = false
+			}
+			seen[port] = true
+		}
+		if ok {
+			return nil
 		}
 	}
-	m.Rooms = append(m.Rooms, rooms.NewRoom(prof, m.OnStateChange))
-	return true
+
+	var ports = &rooms.Ports{}
+	var next = start
+	for _, port := range []*int{&ports.Server, &ports.Query, &ports.Rcon} {
+		for next <= end && used[next] {
+			next++
+		}
+		if next > end {
+			return ErrNoPortAvailable
+		}
+		*port = next
+		used[next] = true
+	}
+	prof.Ports = ports
+	return nil
 }
 
 func (m *Manager) MarshalServerList() []byte {
@@ -128,6 +219,7 @@ func (m *Manager) MarshalServerList() []byte {
 		ServerType versions.ServerType `json:"server-type"`
 		VersionID  string              `json:"version-id"`
 		State      servers.ServerState `json:"state"`
+		Players    int                 `json:"players"`
 	}
 	m.roomsmu.RLock()
 	var srvs = make([]a, 0, len(m.Rooms))
@@ -139,6 +231,7 @@ func (m *Manager) MarshalServerList() []byte {
 			ServerType: r.Profile.Type,
 			VersionID:  r.Profile.VersionID,
 			State:      r.Srv.State,
+			Players:    r.Srv.PlayerCount(),
 		})
 	}
 	m.roomsmu.RUnlock()
@@ -0,0 +1,148 @@
+package mojang
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"mineOS/globals"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	profileUrl = "https://api.mojang.com/users/profiles/minecraft/"
+
+	nameReg = regexp.MustCompile(`^[A-Za-z0-9_]{1,16}$`)
+
+	ErrInvalidName    = fmt.Errorf("invalid player name")
+	ErrPlayerNotFound = fmt.Errorf("player not found")
+
+	client = &http.Client{Timeout: 10 * time.Second}
+
+	cache   map[string]*Profile // by lower case name, nil until loaded
+	cachemu sync.Mutex
+)
+
+// names can be changed, cached uuids are looked up again after this duration
+const cacheTTL = 7 * 24 * time.Hour
+
+type Profile struct {
+	Name string    `json:"name"`
+	UUID string    `json:"uuid"`
+	Time time.Time `json:"time"` // time of the lookup
+}
+
+// ValidName reports whether name is a valid minecraft username
+func ValidName(name string) bool {
+	return nameReg.MatchString(name)
+}
+
+// LookupUUID returns the profile of the player name (with the case used by mojang), results are cached
+//
+// in offline mode, only cached profiles are returned
+func LookupUUID(name string) (Profile, error) {
+	if !ValidName(name) {
+		return Profile{}, ErrInvalidName
+	}
+	cachemu.Lock()
+	loadCache()
+	p, ok := cache[strings.ToLower(name)]
+	cachemu.Unlock()
+	if ok && (time.Since(p.Time) < cacheTTL || globals.OfflineMode.Get()) {
+		return *p, nil
+	}
+	if globals.OfflineMode.Get() {
+		return Profile{}, fmt.Errorf("%w: %v (offline mode)", ErrPlayerNotFound, name)
+	}
+
+	resp, err := client.Get(profileUrl + name)
+	if err != nil {
+		return Profile{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound {
+		return Profile{}, fmt.Errorf("%w: %v", ErrPlayerNotFound, name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Profile{}, fmt.Errorf("mojang api returned %v", resp.Status)
+	}
+	var body = struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return Profile{}, err
+	}
+	uuid, err := FormatUUID(body.ID)
+	if err != nil {
+		return Profile{}, err
+	}
+	var profile = Profile{Name: body.Name, UUID: uuid, Time: time.Now()}
+	cachemu.Lock()
+	cache[strings.ToLower(name)] = &profile
+	err = saveCache()
+	cachemu.Unlock()
+	if err != nil {
+		fmt.Printf("failed to save uuid cache: %v\n", err)
+	}
+	return profile, nil
+}
+
+// OfflineUUID returns the uuid given to name by servers in offline mode (online-mode=false)
+func OfflineUUID(name string) string {
+	var sum = md5.Sum([]byte("OfflinePlayer:" + name))
+	sum[6] = sum[6]&0x0f | 0x30 // version 3
+	sum[8] = sum[8]&0x3f | 0x80 // variant
+	uuid, _ := FormatUUID(fmt.Sprintf("%x", sum))
+	return uuid
+}
+
+// FormatUUID adds dashes to a 32 characters hexadecimal uuid
+func FormatUUID(id string) (string, error) {
+	id = strings.ReplaceAll(id, "-", "")
+	if len(id) != 32 {
+		return "", fmt.Errorf("invalid uuid %q", id)
+	}
+	return id[:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:], nil
+}
+
+func cacheFile() string {
+	return filepath.Join(globals.CacheFolder.WarnGet(), "uuids.json")
+}
+
+// does NOT lock mutexes
+func loadCache() {
+	if cache != nil {
+		return
+	}
+	cache = map[string]*Profile{}
+	f, err := os.Open(cacheFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Printf("failed to load uuid cache: %v\n", err)
+		}
+		return
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&cache)
+	if err != nil {
+		fmt.Printf("failed to load uuid cache: %v\n", err)
+		cache = map[string]*Profile{}
+	}
+}
+
+// does NOT lock mutexes
+func saveCache() error {
+	f, err := os.Create(cacheFile())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(cache)
+}
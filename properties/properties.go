@@ -0,0 +1,264 @@
+package properties
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// line of a properties file; comments and blank lines have an empty key
+type line struct {
+	key   string
+	value string
+	raw   string
+}
+
+// Properties keeps the ordering and comments of a java properties file
+// so that it can be edited and written back without losing information
+type Properties struct {
+	lines []*line
+}
+
+func New() *Properties {
+	return &Properties{lines: []*line{}}
+}
+
+// if file does not exist, Load returns an empty Properties
+func Load(file string) (*Properties, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return New(), nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+func Parse(r io.Reader) (*Properties, error) {
+	var p = New()
+	sc := bufio.NewScanner(r)
+	var raw []string // physical lines of the current logical line
+	for sc.Scan() {
+		var text = sc.Text()
+		raw = append(raw, text)
+		if !isComment(raw[0]) && continues(text) {
+			continue
+		}
+		p.lines = append(p.lines, parseLine(strings.Join(raw, "\n")))
+		raw = nil
+	}
+	if raw != nil {
+		p.lines = append(p.lines, parseLine(strings.Join(raw, "\n")))
+	}
+	return p, sc.Err()
+}
+
+func isComment(raw string) bool {
+	trimmed := strings.TrimLeft(raw, " \t\f")
+	return trimmed == "" || trimmed[0] == '#' || trimmed[0] == '!'
+}
+
+// continues reports whether a line ends with an odd number of backslashes (continued on the next line)
+func continues(text string) bool {
+	var n int
+	for i := len(text) - 1; i >= 0 && text[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// parseLine parses a logical line (physical lines joined by \n) following java.util.Properties rules
+func parseLine(raw string) *line {
+	if isComment(raw) {
+		return &line{raw: raw}
+	}
+	// continuation lines are joined without the backslash and leading whitespace of the next line
+	var parts = strings.Split(raw, "\n")
+	for i := range parts {
+		if i != 0 {
+			parts[i] = strings.TrimLeft(parts[i], " \t\f")
+		}
+		if i != len(parts)-1 {
+			parts[i] = parts[i][:len(parts[i])-1]
+		}
+	}
+	var logical = strings.TrimLeft(strings.Join(parts, ""), " \t\f")
+
+	var i int
+	for ; i < len(logical); i++ {
+		if logical[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", logical[i]) != -1 {
+			break
+		}
+	}
+	if i > len(logical) {
+		i = len(logical)
+	}
+	var key, rest = logical[:i], strings.TrimLeft(logical[i:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	return &line{key: unescape(key), value: unescape(rest), raw: raw}
+}
+
+func unescape(s string) string {
+	if !strings.ContainsRune(s, '\\') {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 16); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// escape escapes s so that it is read back unchanged by java (key also escapes spaces)
+func escape(s string, key bool) string {
+	var b strings.Builder
+	for i, c := range s {
+		switch c {
+		case '\\', '=', ':', '#', '!':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case '\t':
+			b.WriteString("\\t")
+		case '\n':
+			b.WriteString("\\n")
+		case '\r':
+			b.WriteString("\\r")
+		case '\f':
+			b.WriteString("\\f")
+		case ' ':
+			if key || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// last returns the last line of key (the one read by java when a key is repeated), nil if there is none
+func (p *Properties) last(key string) *line {
+	for i := len(p.lines) - 1; i >= 0; i-- {
+		if p.lines[i].key == key {
+			return p.lines[i]
+		}
+	}
+	return nil
+}
+
+func (p *Properties) Get(key string) (string, bool) {
+	if l := p.last(key); l != nil {
+		return l.value, true
+	}
+	return "", false
+}
+
+// Set replaces the value of key in place or appends it at the end of the file
+//
+// if key is repeated, its last occurrence is replaced
+func (p *Properties) Set(key string, value string) {
+	if l := p.last(key); l != nil {
+		l.value = value
+		l.raw = ""
+		return
+	}
+	p.lines = append(p.lines, &line{key: key, value: value})
+}
+
+// Delete removes all occurrences of key, returns false if it was not set
+func (p *Properties) Delete(key string) bool {
+	var lines = p.lines[:0]
+	for _, l := range p.lines {
+		if l.key != key {
+			lines = append(lines, l)
+		}
+	}
+	var found = len(lines) != len(p.lines)
+	p.lines = lines
+	return found
+}
+
+// Keys returns the keys in the order of their first occurrence
+func (p *Properties) Keys() []string {
+	var keys = []string{}
+	var seen = map[string]bool{}
+	for _, l := range p.lines {
+		if l.key != "" && !seen[l.key] {
+			seen[l.key] = true
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+func (p *Properties) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, l := range p.lines {
+		var str = l.raw
+		if l.key != "" && str == "" {
+			str = escape(l.key, true) + "=" + escape(l.value, false)
+		}
+		i, err := io.WriteString(w, str+"\n")
+		n += int64(i)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (p *Properties) Save(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = p.WriteTo(f)
+	return err
+}
+
+// Update loads file, calls f and then saves the result back to file
+func Update(file string, f func(p *Properties)) error {
+	p, err := Load(file)
+	if err != nil {
+		return err
+	}
+	f(p)
+	return p.Save(file)
+}
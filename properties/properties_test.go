@@ -0,0 +1,134 @@
+package properties
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		input string
+		key   string
+		value string
+	}{
+		{"a=b", "a", "b"},
+		{"a = b", "a", "b"},
+		{"a:b", "a", "b"},
+		{"a b", "a", "b"},
+		{"  a=b  ", "a", "b  "},
+		{"a=", "a", ""},
+		{"a", "a", ""},
+		{`a\=b=c`, "a=b", "c"},
+		{`a\ b=c`, "a b", "c"},
+		{"a=b\\\n   c", "a", "bc"},
+		{"a=b\\\n# not a comment", "a", "b# not a comment"},
+		{`a=b\\`, "a", `b\`},
+		{`motd=\u00a7aHello`, "motd", "§aHello"},
+		{`a=x\ty`, "a", "x\ty"},
+	}
+	for _, test := range tests {
+		p, err := Parse(strings.NewReader(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, ok := p.Get(test.key)
+		if !ok || value != test.value {
+			t.Errorf("%q: got %q (%v), want %q", test.input, value, ok, test.value)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	p, err := Parse(strings.NewReader("# a=b\n  ! c=d\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := p.Keys(); len(keys) != 0 {
+		t.Errorf("comments were read as keys: %v", keys)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var file = "#Minecraft server properties\n" +
+		"#Sun Oct 18 10:30:15 UTC 2026\n" +
+		"\n" +
+		"motd=\\u00a7aA Minecraft Server\n" +
+		"level-name = world\n" +
+		"long=first\\\n" +
+		"    second\n" +
+		"max-players=20\n"
+	p, err := Parse(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	p.WriteTo(&b)
+	if b.String() != file {
+		t.Fatalf("unchanged file was rewritten:\n%v", b.String())
+	}
+
+	// only the edited line is rewritten
+	p.Set("max-players", "10")
+	b.Reset()
+	p.WriteTo(&b)
+	if b.String() != strings.Replace(file, "max-players=20", "max-players=10", 1) {
+		t.Fatalf("got:\n%v", b.String())
+	}
+
+	var values = map[string]string{
+		"spaces":       " leading and trailing ",
+		"separators":   "a=b:c#d!e",
+		"escapes":      "tab\there\nnew line\\backslash",
+		"unicode":      "§a héllo",
+		"key with = :": "x",
+		"empty":        "",
+	}
+	for k, v := range values {
+		p.Set(k, v)
+	}
+	b.Reset()
+	p.WriteTo(&b)
+	read, err := Parse(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range values {
+		if got, ok := read.Get(k); !ok || got != v {
+			t.Errorf("%q: got %q (%v), want %q", k, got, ok, v)
+		}
+	}
+}
+
+func TestRepeatedKeys(t *testing.T) {
+	p, err := Parse(strings.NewReader("a=1\nb=2\na=3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// java keeps the last value
+	if v, _ := p.Get("a"); v != "3" {
+		t.Errorf("got %q, want the last value", v)
+	}
+	if keys := p.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("got keys %v", keys)
+	}
+
+	p.Set("a", "4")
+	var b strings.Builder
+	p.WriteTo(&b)
+	if b.String() != "a=1\nb=2\na=4\n" {
+		t.Errorf("set did not replace the last occurrence:\n%v", b.String())
+	}
+
+	if !p.Delete("a") {
+		t.Error("delete returned false")
+	}
+	b.Reset()
+	p.WriteTo(&b)
+	if b.String() != "b=2\n" {
+		t.Errorf("delete did not remove all occurrences:\n%v", b.String())
+	}
+	if p.Delete("a") {
+		t.Error("delete of a missing key returned true")
+	}
+}
@@ -0,0 +1,223 @@
+package properties
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Type string
+
+const (
+	Bool   Type = "bool"
+	Int    Type = "int"
+	String Type = "string"
+	Enum   Type = "enum"
+)
+
+var ErrInvalidValue = fmt.Errorf("invalid value")
+
+// Key describes a known server.properties key
+type Key struct {
+	Type   Type     `json:"type"`
+	Min    int64    `json:"min,omitempty"` // only for Int
+	Max    int64    `json:"max,omitempty"` // only for Int
+	Values []string `json:"values,omitempty"`
+	// numeric values are also accepted for Enum (used by old versions, ex: 1 for easy)
+	Numeric bool `json:"-"`
+	// Command applies the value to a running server ("" if a restart is needed), %v is replaced by the value
+	Command string `json:"-"`
+}
+
+func boolKey() Key {
+	return Key{Type: Bool}
+}
+
+func intKey(min, max int64) Key {
+	return Key{Type: Int, Min: min, Max: max}
+}
+
+func stringKey() Key {
+	return Key{Type: String}
+}
+
+func enumKey(values ...string) Key {
+	return Key{Type: Enum, Values: values, Numeric: true}
+}
+
+func (k Key) withCommand(cmd string) Key {
+	k.Command = cmd
+	return k
+}
+
+// Known keys of vanilla servers, keys that are not listed are accepted as strings
+var Known = map[string]Key{
+	"accepts-transfers":                 boolKey(),
+	"allow-flight":                      boolKey(),
+	"allow-nether":                      boolKey(),
+	"broadcast-console-to-ops":          boolKey(),
+	"broadcast-rcon-to-ops":             boolKey(),
+	"difficulty":                        enumKey("peaceful", "easy", "normal", "hard").withCommand("difficulty %v"),
+	"enable-command-block":              boolKey(),
+	"enable-jmx-monitoring":             boolKey(),
+	"enable-query":                      boolKey(),
+	"enable-rcon":                       boolKey(),
+	"enable-status":                     boolKey(),
+	"enforce-secure-profile":            boolKey(),
+	"enforce-whitelist":                 boolKey(),
+	"entity-broadcast-range-percentage": intKey(10, 1000),
+	"force-gamemode":                    boolKey(),
+	"function-permission-level":         intKey(1, 4),
+	"gamemode":                          enumKey("survival", "creative", "adventure", "spectator").withCommand("defaultgamemode %v"),
+	"generate-structures":               boolKey(),
+	"generator-settings":                stringKey(),
+	"hardcore":                          boolKey(),
+	"hide-online-players":               boolKey(),
+	"level-name":                        stringKey(),
+	"level-seed":                        stringKey(),
+	"level-type":                        stringKey(), // modded servers add their own types
+	"log-ips":                           boolKey(),
+	"max-chained-neighbor-updates":      intKey(math.MinInt32, math.MaxInt32),
+	"max-players":                       intKey(0, math.MaxInt32),
+	"max-tick-time":                     intKey(-1, math.MaxInt64),
+	"max-world-size":                    intKey(1, 29999984),
+	"motd":                              stringKey(),
+	"network-compression-threshold":     intKey(-1, math.MaxInt32),
+	"online-mode":                       boolKey(),
+	"op-permission-level":               intKey(0, 4),
+	"player-idle-timeout":               intKey(0, math.MaxInt32),
+	"prevent-proxy-connections":         boolKey(),
+	"pvp":                               boolKey(),
+	"query.port":                        intKey(1, 65535),
+	"rate-limit":                        intKey(0, math.MaxInt32),
+	"rcon.password":                     stringKey(),
+	"rcon.port":                         intKey(1, 65535),
+	"require-resource-pack":             boolKey(),
+	"resource-pack":                     stringKey(),
+	"resource-pack-id":                  stringKey(),
+	"resource-pack-prompt":              stringKey(),
+	"resource-pack-sha1":                stringKey(),
+	"server-ip":                         stringKey(),
+	"server-port":                       intKey(1, 65535),
+	"simulation-distance":               intKey(3, 32),
+	"spawn-animals":                     boolKey(),
+	"spawn-monsters":                    boolKey(),
+	"spawn-npcs":                        boolKey(),
+	"spawn-protection":                  intKey(0, math.MaxInt32),
+	"sync-chunk-writes":                 boolKey(),
+	"text-filtering-config":             stringKey(),
+	"use-native-transport":              boolKey(),
+	"view-distance":                     intKey(3, 32),
+	"white-list":                        boolKey().withCommand("whitelist %v"),
+}
+
+// Normalize checks value against the known key and returns it in the form written to the file
+// (ex: enum names are lower cased)
+func Normalize(key string, value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") && key != "motd" {
+		return "", fmt.Errorf("%w: %v cannot contain line breaks", ErrInvalidValue, key)
+	}
+	k, ok := Known[key]
+	if !ok {
+		return value, nil
+	}
+	switch k.Type {
+	case Bool:
+		if value != "true" && value != "false" {
+			return "", fmt.Errorf("%w: %v must be true or false", ErrInvalidValue, key)
+		}
+	case Int:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v must be an integer", ErrInvalidValue, key)
+		}
+		if i < k.Min || i > k.Max {
+			return "", fmt.Errorf("%w: %v must be between %v and %v", ErrInvalidValue, key, k.Min, k.Max)
+		}
+	case Enum:
+		var lower = strings.ToLower(value)
+		if i, err := strconv.Atoi(value); err == nil && k.Numeric && i >= 0 && i < len(k.Values) {
+			return value, nil
+		}
+		for _, v := range k.Values {
+			if v == lower {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("%w: %v must be one of %v", ErrInvalidValue, key, strings.Join(k.Values, ", "))
+	}
+	if key == "level-name" && (strings.TrimSpace(value) == "" || strings.ContainsAny(value, `/\`) || value == "..") {
+		return "", fmt.Errorf("%w: level-name must be a folder name", ErrInvalidValue)
+	}
+	return value, nil
+}
+
+// Validate returns the errors of all invalid values of p, by key (nil if there is none)
+func (p *Properties) Validate() map[string]string {
+	var errs map[string]string
+	for _, l := range p.lines {
+		if l.key == "" {
+			continue
+		}
+		if _, err := Normalize(l.key, l.value); err != nil {
+			if errs == nil {
+				errs = map[string]string{}
+			}
+			errs[l.key] = err.Error()
+		}
+	}
+	return errs
+}
+
+// Typed returns the values of p converted to their json type (bool, int64 or string)
+//
+// values that do not match the type of a known key are returned as strings
+func (p *Properties) Typed() map[string]interface{} {
+	var m = map[string]interface{}{}
+	for _, l := range p.lines {
+		if l.key == "" {
+			continue
+		}
+		m[l.key] = l.value
+		switch Known[l.key].Type {
+		case Bool:
+			if b, err := strconv.ParseBool(l.value); err == nil {
+				m[l.key] = b
+			}
+		case Int:
+			if i, err := strconv.ParseInt(l.value, 10, 64); err == nil {
+				m[l.key] = i
+			}
+		}
+	}
+	return m
+}
+
+// FromJSON converts a decoded json value (bool, number or string) to a property value
+func FromJSON(key string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return "", fmt.Errorf("%w: %v must be an integer", ErrInvalidValue, key)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("%w: %v must be a string, a number or a boolean", ErrInvalidValue, key)
+}
+
+// LiveCommand returns the command applying value to a running server ("" if a restart is needed)
+func LiveCommand(key string, value string) string {
+	var k = Known[key]
+	if k.Command == "" {
+		return ""
+	}
+	if k.Type == Bool {
+		value = map[string]string{"true": "on", "false": "off"}[value]
+	}
+	return fmt.Sprintf(k.Command, value)
+}
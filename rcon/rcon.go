@@ -0,0 +1,170 @@
+package rcon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	typeResponse int32 = 0
+	typeCommand  int32 = 2
+	typeAuth     int32 = 3
+	// minecraft answers unknown types with a response of the same id,
+	// it is used to know when a fragmented response is complete
+	typeMarker int32 = 100
+
+	maxPacketSize = 4096 + 14
+)
+
+var (
+	ErrAuthFailed    = errors.New("rcon authentication failed")
+	ErrInvalidPacket = errors.New("invalid rcon packet")
+	ErrTooLong       = errors.New("rcon command too long")
+	// the command was sent but its response could not be read: it may have run
+	ErrNoResponse = errors.New("no rcon response")
+)
+
+type Client struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	lastID  int32
+	mu      sync.Mutex
+}
+
+// Dial connects to the rcon server at addr and authenticates with password
+//
+// timeout applies to connection and to each command
+func Dial(addr string, password string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	var c = &Client{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: timeout,
+	}
+	err = c.auth(password)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) nextID() int32 {
+	c.lastID++
+	if c.lastID <= 0 {
+		c.lastID = 1
+	}
+	return c.lastID
+}
+
+func (c *Client) auth(password string) error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	var id = c.nextID()
+	err := c.write(id, typeAuth, password)
+	if err != nil {
+		return err
+	}
+	for {
+		rid, typ, _, err := c.read()
+		if err != nil {
+			return err
+		}
+		if rid == -1 {
+			return ErrAuthFailed
+		}
+		// some servers send an empty response before the auth response
+		if typ == typeCommand && rid == id {
+			return nil
+		}
+	}
+}
+
+// Command runs cmd on the server and returns its output, within the timeout given to Dial
+func (c *Client) Command(cmd string) (string, error) {
+	return c.CommandTimeout(cmd, c.timeout)
+}
+
+// CommandTimeout runs cmd on the server and returns its output
+//
+// errors reading the response wrap ErrNoResponse, the connection can no longer be used then
+func (c *Client) CommandTimeout(cmd string, timeout time.Duration) (string, error) {
+	if len(cmd) > 1446 { // limit of minecraft
+		return "", ErrTooLong
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	var id, marker = c.nextID(), c.nextID()
+	err := c.write(id, typeCommand, cmd)
+	if err != nil {
+		return "", err
+	}
+	err = c.write(marker, typeMarker, "")
+	if err != nil {
+		return "", err
+	}
+	var out = &bytes.Buffer{}
+	for {
+		rid, _, body, err := c.read()
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrNoResponse, err)
+		}
+		switch rid {
+		case id:
+			out.WriteString(body)
+		case marker:
+			return out.String(), nil
+		}
+	}
+}
+
+func (c *Client) write(id int32, typ int32, body string) error {
+	var buf = &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, int32(len(body)+10))
+	binary.Write(buf, binary.LittleEndian, id)
+	binary.Write(buf, binary.LittleEndian, typ)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+	_, err := c.conn.Write(buf.Bytes())
+	return err
+}
+
+func (c *Client) read() (id int32, typ int32, body string, err error) {
+	var size int32
+	err = binary.Read(c.r, binary.LittleEndian, &size)
+	if err != nil {
+		return
+	}
+	if size < 10 || size > maxPacketSize {
+		err = fmt.Errorf("%w: size %v", ErrInvalidPacket, size)
+		return
+	}
+	var data = make([]byte, size)
+	_, err = io.ReadFull(c.r, data)
+	if err != nil {
+		return
+	}
+	id = int32(binary.LittleEndian.Uint32(data[0:4]))
+	typ = int32(binary.LittleEndian.Uint32(data[4:8]))
+	body = string(bytes.TrimRight(data[8:], "\x00"))
+	if typ == typeResponse || typ == typeCommand {
+		return
+	}
+	err = fmt.Errorf("%w: unknown type %v", ErrInvalidPacket, typ)
+	return
+}
@@ -0,0 +1,236 @@
+package rcon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer is a minimal rcon listener behaving like minecraft
+type fakeServer struct {
+	ln       net.Listener
+	password string
+	// fragments of the response to a command, each one sent as a packet
+	respond func(cmd string) []string
+	// if set, the connection is closed instead of answering commands
+	hangUp bool
+	// if set, an empty response is sent before the auth response
+	emptyBeforeAuth bool
+}
+
+func startFake(t *testing.T, f *fakeServer) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ln = ln
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (f *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	var r = bufio.NewReader(conn)
+	for {
+		id, typ, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case typeAuth:
+			if f.emptyBeforeAuth {
+				writePacket(conn, id, typeResponse, "")
+			}
+			if body != f.password {
+				id = -1
+			}
+			writePacket(conn, id, typeCommand, "")
+		case typeCommand:
+			if f.hangUp {
+				return
+			}
+			for _, frag := range f.respond(body) {
+				writePacket(conn, id, typeResponse, frag)
+			}
+		default:
+			writePacket(conn, id, typeResponse, "Unknown request 64")
+		}
+	}
+}
+
+func writePacket(w io.Writer, id int32, typ int32, body string) {
+	var buf = &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, int32(len(body)+10))
+	binary.Write(buf, binary.LittleEndian, id)
+	binary.Write(buf, binary.LittleEndian, typ)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+	w.Write(buf.Bytes())
+}
+
+func readPacket(r io.Reader) (id int32, typ int32, body string, err error) {
+	var size int32
+	err = binary.Read(r, binary.LittleEndian, &size)
+	if err != nil {
+		return
+	}
+	var data = make([]byte, size)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return
+	}
+	id = int32(binary.LittleEndian.Uint32(data[0:4]))
+	typ = int32(binary.LittleEndian.Uint32(data[4:8]))
+	body = string(bytes.TrimRight(data[8:], "\x00"))
+	return
+}
+
+func echo(cmd string) []string {
+	return []string{"ran " + cmd}
+}
+
+func TestAuth(t *testing.T) {
+	var addr = startFake(t, &fakeServer{password: "secret", respond: echo})
+	c, err := Dial(addr, "secret", time.Second)
+	if err != nil {
+		t.Fatalf("auth with the right password failed: %v", err)
+	}
+	defer c.Close()
+	out, err := c.Command("list")
+	if err != nil || out != "ran list" {
+		t.Fatalf("got %q, %v", out, err)
+	}
+}
+
+func TestAuthEmptyResponseFirst(t *testing.T) {
+	var addr = startFake(t, &fakeServer{password: "secret", respond: echo, emptyBeforeAuth: true})
+	c, err := Dial(addr, "secret", time.Second)
+	if err != nil {
+		t.Fatalf("auth failed: %v", err)
+	}
+	c.Close()
+}
+
+func TestAuthFailed(t *testing.T) {
+	var addr = startFake(t, &fakeServer{password: "secret", respond: echo})
+	_, err := Dial(addr, "wrong", time.Second)
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("expected ErrAuthFailed, got %v", err)
+	}
+}
+
+func TestMultiPacketResponse(t *testing.T) {
+	var long = strings.Repeat("a", 4096) + strings.Repeat("b", 4096) + "c"
+	var addr = startFake(t, &fakeServer{password: "secret", respond: func(cmd string) []string {
+		return []string{long[:4096], long[4096:8192], long[8192:]}
+	}})
+	c, err := Dial(addr, "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	out, err := c.Command("help")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != long {
+		t.Fatalf("fragments were not joined: got %v bytes, want %v", len(out), len(long))
+	}
+	// the next command must not see leftovers of the previous response
+	out, err = c.Command("list")
+	if err != nil || out != long {
+		t.Fatalf("second command: got %v bytes, %v", len(out), err)
+	}
+}
+
+func TestEmptyResponse(t *testing.T) {
+	var addr = startFake(t, &fakeServer{password: "secret", respond: func(string) []string { return nil }})
+	c, err := Dial(addr, "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	out, err := c.Command("save-off")
+	if err != nil || out != "" {
+		t.Fatalf("got %q, %v", out, err)
+	}
+}
+
+// the command may have run: servers.Exec must not send it again through stdin
+func TestCommandFailsWhenConnectionLost(t *testing.T) {
+	var addr = startFake(t, &fakeServer{password: "secret", respond: echo, hangUp: true})
+	c, err := Dial(addr, "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, err = c.Command("list")
+	if !errors.Is(err, ErrNoResponse) {
+		t.Fatalf("expected ErrNoResponse, got %v", err)
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	var addr = startFake(t, &fakeServer{password: "secret", respond: func(string) []string {
+		time.Sleep(time.Second)
+		return nil
+	}})
+	c, err := Dial(addr, "secret", 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var start = time.Now()
+	_, err = c.Command("list")
+	if !errors.Is(err, ErrNoResponse) {
+		t.Fatalf("expected ErrNoResponse, got %v", err)
+	}
+	if time.Since(start) > 900*time.Millisecond {
+		t.Fatalf("command did not time out: %v", time.Since(start))
+	}
+}
+
+func TestCommandLongerTimeout(t *testing.T) {
+	var addr = startFake(t, &fakeServer{password: "secret", respond: func(cmd string) []string {
+		time.Sleep(300 * time.Millisecond)
+		return []string{"Saved the game"}
+	}})
+	c, err := Dial(addr, "secret", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	out, err := c.CommandTimeout("save-all flush", time.Second)
+	if err != nil || out != "Saved the game" {
+		t.Fatalf("got %q, %v", out, err)
+	}
+}
+
+func TestCommandTooLong(t *testing.T) {
+	var addr = startFake(t, &fakeServer{password: "secret", respond: echo})
+	c, err := Dial(addr, "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, err = c.Command(strings.Repeat("a", 1447))
+	if !errors.Is(err, ErrTooLong) {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+}
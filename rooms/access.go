@@ -0,0 +1,280 @@
+package rooms
+
+import (
+	"encoding/json"
+	"fmt"
+	"mineOS/mojang"
+	"mineOS/properties"
+	"mineOS/servers"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessList is one of the player lists kept by the server in its folder
+type AccessList string
+
+const (
+	Whitelist     AccessList = "whitelist"
+	Ops           AccessList = "ops"
+	BannedPlayers AccessList = "banned-players"
+	BannedIPs     AccessList = "banned-ips"
+)
+
+// time format used by the server in ban lists
+const banTimeFormat = "2006-01-02 15:04:05 -0700"
+
+var (
+	ErrInvalidEntry  = fmt.Errorf("invalid entry")
+	ErrEntryNotFound = fmt.Errorf("entry not found")
+	ErrServerBusy    = fmt.Errorf("server is starting or stopping")
+)
+
+// AccessEntry describes an entry to add to an access list
+type AccessEntry struct {
+	Name   string `json:"name"`   // not used by banned-ips
+	IP     string `json:"ip"`     // only used by banned-ips
+	Reason string `json:"reason"` // only used by ban lists
+	Level  int    `json:"level"`  // only used by ops when the server is closed, 0 uses op-permission-level
+}
+
+func (l AccessList) file(dir string) string {
+	return filepath.Join(dir, string(l)+".json")
+}
+
+func (l AccessList) valid() bool {
+	switch l {
+	case Whitelist, Ops, BannedPlayers, BannedIPs:
+		return true
+	}
+	return false
+}
+
+// GetAccessList returns the entries of list as written by the server
+func (r *Room) GetAccessList(list AccessList) ([]map[string]interface{}, error) {
+	if !list.valid() {
+		return nil, ErrInvalidEntry
+	}
+	r.accessmu.Lock()
+	defer r.accessmu.Unlock()
+	return r.readAccessList(list)
+}
+
+// AddAccess adds e to list; if the server is running the matching command is sent
+// (its output is returned), if it is closed the file is edited
+func (r *Room) AddAccess(list AccessList, e AccessEntry) (string, bool, error) {
+	if !list.valid() {
+		return "", false, ErrInvalidEntry
+	}
+	var key = e.Name
+	if list == BannedIPs {
+		key = e.IP
+	}
+	err := validKey(list, key)
+	if err != nil {
+		return "", false, err
+	}
+	e.Reason = strings.Join(strings.Fields(e.Reason), " ") // no line breaks in commands
+	if e.Level < 0 || e.Level > 4 {
+		return "", false, fmt.Errorf("%w: op level must be between 0 (op-permission-level) and 4", ErrInvalidEntry)
+	}
+
+	if r.Srv.State == servers.Running {
+		if list == Ops && e.Level != 0 { // the op command has no level
+			return "", false, fmt.Errorf("%w: op level can only be set while the server is closed", ErrInvalidEntry)
+		}
+		var cmd string
+		switch list {
+		case Whitelist:
+			cmd = "whitelist add " + key
+		case Ops:
+			cmd = "op " + key
+		case BannedPlayers:
+			cmd = strings.TrimSpace("ban " + key + " " + e.Reason)
+		case BannedIPs:
+			cmd = strings.TrimSpace("ban-ip " + key + " " + e.Reason)
+		}
+		out, _, err := r.SendCommand(cmd)
+		return out, true, err
+	}
+	if !r.Srv.State.IsClosed() {
+		return "", false, ErrServerBusy
+	}
+
+	var entry = map[string]interface{}{}
+	if list == BannedIPs {
+		entry["ip"] = key
+	} else {
+		uuid, name, err := r.resolvePlayer(key)
+		if err != nil {
+			return "", false, err
+		}
+		entry["uuid"], entry["name"] = uuid, name
+	}
+	switch list {
+	case Ops:
+		if e.Level == 0 {
+			e.Level = r.defaultOpLevel()
+		}
+		entry["level"] = e.Level
+		entry["bypassesPlayerLimit"] = false
+	case BannedPlayers, BannedIPs:
+		if e.Reason == "" {
+			e.Reason = "Banned by an operator."
+		}
+		entry["created"] = time.Now().Format(banTimeFormat)
+		entry["source"] = "mineOS"
+		entry["expires"] = "forever"
+		entry["reason"] = e.Reason
+	}
+	return "", false, r.editAccessList(list, func(entries []map[string]interface{}) ([]map[string]interface{}, error) {
+		if i := findEntry(entries, list, key); i != -1 {
+			entries[i] = entry
+			return entries, nil
+		}
+		return append(entries, entry), nil
+	})
+}
+
+// RemoveAccess removes the entry with the given name (or ip for banned-ips), see AddAccess
+func (r *Room) RemoveAccess(list AccessList, key string) (string, bool, error) {
+	if !list.valid() {
+		return "", false, ErrInvalidEntry
+	}
+	err := validKey(list, key)
+	if err != nil {
+		return "", false, err
+	}
+
+	if r.Srv.State == servers.Running {
+		var cmd string
+		switch list {
+		case Whitelist:
+			cmd = "whitelist remove " + key
+		case Ops:
+			cmd = "deop " + key
+		case BannedPlayers:
+			cmd = "pardon " + key
+		case BannedIPs:
+			cmd = "pardon-ip " + key
+		}
+		out, _, err := r.SendCommand(cmd)
+		return out, true, err
+	}
+	if !r.Srv.State.IsClosed() {
+		return "", false, ErrServerBusy
+	}
+
+	return "", false, r.editAccessList(list, func(entries []map[string]interface{}) ([]map[string]interface{}, error) {
+		var i = findEntry(entries, list, key)
+		if i == -1 {
+			return nil, ErrEntryNotFound
+		}
+		return append(entries[:i], entries[i+1:]...), nil
+	})
+}
+
+// editAccessList replaces the entries of list by the ones returned by edit,
+// the server is kept in maintenance meanwhile so that it cannot start and overwrite the file
+func (r *Room) editAccessList(list AccessList, edit func([]map[string]interface{}) ([]map[string]interface{}, error)) error {
+	err := r.Srv.Maintain(servers.Maintenance, func() error {
+		r.accessmu.Lock()
+		defer r.accessmu.Unlock()
+		entries, err := r.readAccessList(list)
+		if err != nil {
+			return err
+		}
+		entries, err = edit(entries)
+		if err != nil {
+			return err
+		}
+		return r.writeAccessList(list, entries)
+	})
+	if err == servers.ErrNotClosed { // started since the state was checked
+		return ErrServerBusy
+	}
+	return err
+}
+
+func validKey(list AccessList, key string) error {
+	if list == BannedIPs {
+		if net.ParseIP(key) == nil {
+			return fmt.Errorf("%w: invalid ip %q", ErrInvalidEntry, key)
+		}
+		return nil
+	}
+	if !mojang.ValidName(key) {
+		return fmt.Errorf("%w: invalid player name %q", ErrInvalidEntry, key)
+	}
+	return nil
+}
+
+// findEntry returns the index of the entry matching key (-1 if there is none)
+func findEntry(entries []map[string]interface{}, list AccessList, key string) int {
+	var field = "name"
+	if list == BannedIPs {
+		field = "ip"
+	}
+	for i, e := range entries {
+		if v, ok := e[field].(string); ok && strings.EqualFold(v, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolvePlayer returns the uuid of name as used by the server (offline uuids if online-mode is false)
+func (r *Room) resolvePlayer(name string) (string, string, error) {
+	props, err := properties.Load(r.Profile.GetServerPropertiesFile())
+	if err != nil {
+		return "", "", err
+	}
+	if mode, _ := props.Get("online-mode"); mode == "false" {
+		return mojang.OfflineUUID(name), name, nil
+	}
+	p, err := mojang.LookupUUID(name)
+	if err != nil {
+		return "", "", err
+	}
+	return p.UUID, p.Name, nil
+}
+
+func (r *Room) defaultOpLevel() int {
+	props, err := properties.Load(r.Profile.GetServerPropertiesFile())
+	if err == nil {
+		str, _ := props.Get("op-permission-level")
+		if level, err := strconv.Atoi(str); err == nil && level >= 1 && level <= 4 {
+			return level
+		}
+	}
+	return 4
+}
+
+// does NOT lock mutexes
+func (r *Room) readAccessList(list AccessList) ([]map[string]interface{}, error) {
+	var entries = []map[string]interface{}{}
+	data, err := os.ReadFile(list.file(filepath.Dir(r.Profile.JarPath)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return entries, nil
+	}
+	err = json.Unmarshal(data, &entries)
+	return entries, err
+}
+
+// does NOT lock mutexes
+func (r *Room) writeAccessList(list AccessList, entries []map[string]interface{}) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(list.file(filepath.Dir(r.Profile.JarPath)), data, 0666)
+}
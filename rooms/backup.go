@@ -0,0 +1,312 @@
+package rooms
+
+import (
+	"fmt"
+	"io"
+	"mineOS/archive"
+	"mineOS/downloads"
+	"mineOS/globals"
+	"mineOS/servers"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/Amqp-prtcl/snowflakes"
+)
+
+var (
+	//[12:00:00] [Server thread/INFO]: Saved the game (Saved the world on old versions), "[Rcon: Saved the game]" when sent through rcon
+	savedReg = regexp.MustCompile(`^(\[Rcon: )?Saved the (game|world)`)
+
+	ErrBackupRunning = fmt.Errorf("a backup is already running")
+	ErrStreamTimeout = fmt.Errorf("hot archive was not sent in time")
+)
+
+// DefaultBackupRules returns the backup rules of new servers: files that can be downloaded again or are not worth keeping
+func DefaultBackupRules() []string {
+	return []string{
+		"/server.jar",
+		"/logs/",
+		"/cache/",
+		"/crash-reports/",
+		"/plugins/dynmap/web/tiles/",
+	}
+}
+
+// SetBackupRules validates rules before replacing the backup rules of the room, nil restores the defaults
+func (r *Room) SetBackupRules(rules []string) error {
+	if rules == nil {
+		rules = DefaultBackupRules()
+	}
+	_, err := archive.ParseRules(rules)
+	if err != nil {
+		return err
+	}
+	r.rulesmu.Lock()
+	r.Profile.BackupRules = append([]string{}, rules...)
+	r.rulesmu.Unlock()
+	r.saveProfile()
+	return nil
+}
+
+// GetBackupRules returns a copy of the backup rules of the room
+func (r *Room) GetBackupRules() []string {
+	r.rulesmu.Lock()
+	defer r.rulesmu.Unlock()
+	return append([]string{}, r.Profile.BackupRules...)
+}
+
+// backupRules returns the rules of the files left out of backups, the supervisor folder is always left out
+func (r *Room) backupRules() *archive.Rules {
+	var patterns = append(r.GetBackupRules(), "/"+servers.SupervisorFolder+"/")
+	rules, err := archive.ParseRules(patterns)
+	if err != nil {
+		fmt.Printf("invalid backup rules of server %v, backing up everything: %v\n", r.Profile.ID, err)
+		rules, _ = archive.ParseRules([]string{"/" + servers.SupervisorFolder + "/"})
+	}
+	return rules
+}
+
+// time given to the server to write the world to disk before a hot backup
+const saveTimeout = 5 * time.Minute
+
+// time given to a hot archive to be streamed, saving is disabled meanwhile
+const hotStreamTimeout = 30 * time.Minute
+
+// minimum time between two backup-progress events while archiving
+const progressInterval = time.Second
+
+type BackupStage string
+
+const (
+	StageSaving    BackupStage = "saving" // waiting for the server to write the world to disk
+	StageArchiving BackupStage = "archiving"
+)
+
+type BackupProgress struct {
+	ServerID snowflakes.ID `json:"server-id"`
+	Stage    BackupStage   `json:"stage"`
+	Hot      bool          `json:"hot"`
+	Files    int           `json:"files"`
+	Bytes    int64         `json:"bytes"`
+	// size of the server folder when archiving started
+	TotalBytes int64 `json:"total-bytes"`
+}
+
+// Backup archives the server folder as a download in the given format (the backup-format setting if empty)
+// and returns its ID
+//
+// a running server is backed up hot: automatic saving is disabled (save-off) and the world
+// is written to disk (save-all flush) before the files are archived, then saving is enabled again (save-on);
+// a closed server is backed up cold (state ZIPPING)
+//
+// progress is reported with backup-progress events, then a backup-done or backup-failed event
+func (r *Room) Backup(format archive.Format) (snowflakes.ID, error) {
+	format, err := BackupFormat(format)
+	if err != nil {
+		return "", err
+	}
+	hot, err := r.beginBackup()
+	if err != nil {
+		return "", err
+	}
+	return r.backup(hot, format)
+}
+
+// StartBackup runs Backup in the background once it checked the backup can start
+func (r *Room) StartBackup(format archive.Format) error {
+	format, err := BackupFormat(format)
+	if err != nil {
+		return err
+	}
+	hot, err := r.beginBackup()
+	if err != nil {
+		return err
+	}
+	go r.backup(hot, format)
+	return nil
+}
+
+// BackupFormat returns format, or the backup-format setting if empty
+func BackupFormat(format archive.Format) (archive.Format, error) {
+	if format != "" {
+		_, err := archive.New(format)
+		return format, err
+	}
+	format = archive.Format(globals.BackupFormat.Get())
+	if !format.Valid() {
+		fmt.Printf("invalid backup-format setting %q, using %v\n", format, archive.Zip)
+		return archive.Zip, nil
+	}
+	return format, nil
+}
+
+// beginBackup marks a backup as running, returns true if it must be hot
+func (r *Room) beginBackup() (bool, error) {
+	r.backupmu.Lock()
+	defer r.backupmu.Unlock()
+	if r.backingUp {
+		return false, ErrBackupRunning
+	}
+	var st = r.Srv.State
+	if st != servers.Running && !st.IsClosed() {
+		return false, ErrServerBusy
+	}
+	r.backingUp = true
+	return st == servers.Running, nil
+}
+
+func (r *Room) endBackup() {
+	r.backupmu.Lock()
+	r.backingUp = false
+	r.backupmu.Unlock()
+}
+
+func (r *Room) backup(hot bool, format archive.Format) (snowflakes.ID, error) {
+	defer r.endBackup()
+	var id snowflakes.ID
+	var archiveFiles = func() error {
+		var err error
+		id, err = r.backupFiles(hot, format)
+		return err
+	}
+	var err error
+	if hot {
+		r.sendEvent("backup-progress", BackupProgress{ServerID: r.Profile.ID, Stage: StageSaving, Hot: true})
+		err = r.hotBackup(archiveFiles)
+	} else {
+		err = r.Srv.Maintain(servers.Zipping, archiveFiles)
+	}
+	if err != nil {
+		fmt.Printf("backup of server %v failed: %v\n", r.Profile.ID, err)
+		r.sendEvent("backup-failed", struct {
+			ServerID snowflakes.ID `json:"server-id"`
+			Hot      bool          `json:"hot"`
+			Error    string        `json:"error"`
+		}{r.Profile.ID, hot, err.Error()})
+		return id, err
+	}
+	r.sendEvent("backup-done", struct {
+		ServerID   snowflakes.ID  `json:"server-id"`
+		Hot        bool           `json:"hot"`
+		Format     archive.Format `json:"format"`
+		DownloadID snowflakes.ID  `json:"download-id"`
+	}{r.Profile.ID, hot, format, id})
+	return id, nil
+}
+
+// savedRecord tells whether rec is logged by the server once the world was saved (players cannot fake it with chat)
+func savedRecord(rec *servers.LogRecord) bool {
+	return rec.Thread == "Server thread" && rec.Level == servers.Info && savedReg.MatchString(rec.Message)
+}
+
+// hotBackup runs f once the running server wrote the world to disk, with automatic saving disabled
+func (r *Room) hotBackup(f func() error) error {
+	saved, cancel := r.Srv.WatchLog(savedRecord)
+	defer cancel()
+	_, _, err := r.SendCommand("save-off")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _, err := r.SendCommand("save-on")
+		if err != nil {
+			fmt.Printf("failed to enable saving again on server %v: %v\n", r.Profile.ID, err)
+		}
+	}()
+	// saving a large world can take longer than other commands
+	out, _, err := r.sendCommandTimeout("save-all flush", saveTimeout)
+	if err != nil {
+		return err
+	}
+	// through rcon, the command only returns once the world is saved
+	if !savedReg.MatchString(out) {
+		select {
+		case _, ok := <-saved:
+			if !ok {
+				return fmt.Errorf("server exited before saving the world")
+			}
+		case <-time.After(saveTimeout):
+			return fmt.Errorf("server did not save the world within %v", saveTimeout)
+		}
+	}
+	return f()
+}
+
+// StreamArchive writes an archive of the server folder to wr in the given format (the backup-format setting
+// if empty), the backup rules apply
+//
+// as for Backup, a running server is archived hot and a closed one cold (state ZIPPING),
+// but no download is made and no event is sent; the archive is written as fast as wr accepts it,
+// a hot archive fails with ErrStreamTimeout if it is not written within hotStreamTimeout
+func (r *Room) StreamArchive(wr io.Writer, format archive.Format) error {
+	format, err := BackupFormat(format)
+	if err != nil {
+		return err
+	}
+	hot, err := r.beginBackup()
+	if err != nil {
+		return err
+	}
+	defer r.endBackup()
+	var archiveFiles = func() error {
+		return archive.Archive(format, filepath.Dir(r.Profile.JarPath), wr, archive.Options{Exclude: r.backupRules()})
+	}
+	if hot {
+		wr = &deadlineWriter{w: wr, deadline: time.Now().Add(hotStreamTimeout)}
+		return r.hotBackup(archiveFiles)
+	}
+	return r.Srv.Maintain(servers.Zipping, archiveFiles)
+}
+
+// deadlineWriter fails with ErrStreamTimeout once deadline is reached
+type deadlineWriter struct {
+	w        io.Writer
+	deadline time.Time
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	if time.Now().After(d.deadline) {
+		return 0, ErrStreamTimeout
+	}
+	return d.w.Write(p)
+}
+
+// backupFiles archives the server folder (except files excluded by the backup rules) as a download,
+// without checking the state of the server
+//
+// format is checked by the caller (the backup-format setting is used if empty);
+// progress is reported with backup-progress events
+func (r *Room) backupFiles(hot bool, format archive.Format) (snowflakes.ID, error) {
+	if format == "" {
+		format, _ = BackupFormat("")
+	}
+	var dir = filepath.Dir(r.Profile.JarPath)
+	var progress = BackupProgress{ServerID: r.Profile.ID, Stage: StageArchiving, Hot: hot}
+	var rules = r.backupRules()
+	progress.TotalBytes, _ = archive.Size(dir, rules)
+	r.sendEvent("backup-progress", progress)
+
+	var name = fmt.Sprintf("backup-server-%s-%v%s", r.Profile.Name, time.Now().UnixMilli(), format.Extension())
+	wr, id, err := downloads.NewFile(name, 30*24*time.Hour)
+	if err != nil {
+		return id, err
+	}
+	var last = time.Now()
+	err = archive.Archive(format, dir, wr, archive.Options{
+		Exclude: rules,
+		Progress: func(files int, bytes int64) {
+			if time.Since(last) < progressInterval {
+				return
+			}
+			last = time.Now()
+			progress.Files, progress.Bytes = files, bytes
+			r.sendEvent("backup-progress", progress)
+		},
+	})
+	if err != nil {
+		wr.Abort()
+		return "", err
+	}
+	return id, wr.Close()
+}
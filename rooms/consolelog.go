@@ -0,0 +1,101 @@
+package rooms
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mineOS/globals"
+	"mineOS/servers"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ConsoleLogName is the file, in the logs folder of a room, where mineOS
+// keeps the console output (stdout and stderr) and state changes of the server
+const ConsoleLogName = "mineos.log"
+
+// consoleLog is rotated on open once it exceeds the console-log-max-size config key
+type consoleLog struct {
+	f  *os.File
+	mu sync.Mutex
+}
+
+func openConsoleLog(dir string) (*consoleLog, error) {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	var path = filepath.Join(dir, ConsoleLogName)
+	if info, err := os.Stat(path); err == nil && info.Size() > globals.ConsoleLogMaxSize.Get() {
+		err = rotateConsoleLog(path, filepath.Join(dir, fmt.Sprintf("mineos-%v.log.gz", time.Now().UnixMilli())))
+		if err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &consoleLog{f: f}, nil
+}
+
+// rotateConsoleLog compresses src into dst before removing it
+func rotateConsoleLog(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err != nil {
+		gz.Close()
+		return err
+	}
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+func (l *consoleLog) write(str string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := io.WriteString(l.f, str)
+	if err != nil {
+		fmt.Printf("failed to write console log %v: %v\n", l.f.Name(), err)
+	}
+}
+
+// stderr lines are prefixed with [stderr]
+func (l *consoleLog) writeRecord(rec *servers.LogRecord) {
+	if rec.Stream == servers.Stderr {
+		l.write("[stderr] " + rec.Raw)
+		return
+	}
+	l.write(rec.Raw)
+}
+
+func (l *consoleLog) writeState(st servers.ServerState) {
+	l.write(fmt.Sprintf("[mineOS] [%v] state: %v\n", time.Now().Format("2006-01-02 15:04:05"), st))
+}
+
+func (l *consoleLog) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.f.Close()
+}
@@ -0,0 +1,152 @@
+package rooms
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Amqp-prtcl/snowflakes"
+)
+
+// StopReason tells why mineOS stopped a server
+type StopReason string
+
+const (
+	StopUser     StopReason = "user"
+	StopSchedule StopReason = "schedule"
+	StopRestart  StopReason = "restart"
+	StopIdle     StopReason = "idle"
+)
+
+const (
+	idleCheckInterval  = 30 * time.Second
+	defaultIdleWarning = "No players online, the server will stop in %v seconds"
+)
+
+var (
+	ErrInvalidIdleShutdown = fmt.Errorf("invalid idle shutdown")
+)
+
+// IdleShutdown stops a running server once no player has been online for Minutes
+type IdleShutdown struct {
+	Minutes int `json:"minutes"` // 0 disables idle shutdown
+	// if not 0, Warning is said in game and the server is only stopped if still empty after WarningSeconds
+	WarningSeconds int    `json:"warning-seconds"`
+	Warning        string `json:"warning"` // empty for the default warning
+}
+
+func (i *IdleShutdown) Validate() error {
+	if i.Minutes < 0 || i.WarningSeconds < 0 {
+		return fmt.Errorf("%w: negative values", ErrInvalidIdleShutdown)
+	}
+	return nil
+}
+
+func (i *IdleShutdown) warning() string {
+	if i.Warning != "" {
+		return i.Warning
+	}
+	return fmt.Sprintf(defaultIdleWarning, i.WarningSeconds)
+}
+
+func (r *Room) SetIdleShutdown(i *IdleShutdown) error {
+	err := i.Validate()
+	if err != nil {
+		return err
+	}
+	var c = *i
+	r.settingsmu.Lock()
+	r.Profile.Idle = &c
+	r.settingsmu.Unlock()
+	r.saveProfile()
+	return nil
+}
+
+// GetIdleShutdown returns the idle shutdown settings of the room, they must not be modified
+func (r *Room) GetIdleShutdown() *IdleShutdown {
+	r.settingsmu.Lock()
+	defer r.settingsmu.Unlock()
+	return r.Profile.Idle
+}
+
+// onlinePlayers counts players from the console, or from a status ping if the console shows none
+func (r *Room) onlinePlayers() int {
+	var count = r.Srv.PlayerCount()
+	if count == 0 {
+		if st, err := r.Srv.Status(2 * time.Second); err == nil {
+			count = st.Online
+		}
+	}
+	return count
+}
+
+// watchIdle stops the server once it has been empty for too long, until done is closed
+func (r *Room) watchIdle(done chan struct{}) {
+	var ticker = time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+	var since = time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		var idle = *r.GetIdleShutdown()
+		if idle.Minutes <= 0 || r.onlinePlayers() != 0 {
+			since = time.Now()
+			continue
+		}
+		if time.Since(since) < time.Duration(idle.Minutes)*time.Minute {
+			continue
+		}
+		if r.idleShutdown(idle, done) {
+			return
+		}
+		since = time.Now() // a player joined during the warning
+	}
+}
+
+// idleShutdown warns players then stops the server if it is still empty, returns true if it was stopped
+func (r *Room) idleShutdown(idle IdleShutdown, done chan struct{}) bool {
+	if idle.WarningSeconds > 0 {
+		_, _, err := r.SendCommand("say " + idle.warning())
+		if err != nil {
+			fmt.Printf("failed to warn players of server %v: %v\n", r.Profile.ID, err)
+		}
+		select {
+		case <-done:
+			return true
+		case <-time.After(time.Duration(idle.WarningSeconds) * time.Second):
+		}
+		if r.onlinePlayers() != 0 {
+			return false
+		}
+	}
+	err := r.stop(false, StopIdle)
+	if err != nil {
+		fmt.Printf("failed to stop idle server %v: %v\n", r.Profile.ID, err)
+		return false
+	}
+	r.sendEvent("idle-shutdown", struct {
+		ServerID snowflakes.ID `json:"server-id"`
+		Minutes  int           `json:"minutes"`
+	}{r.Profile.ID, idle.Minutes})
+	return true
+}
+
+// does NOT lock mutexes
+func (r *Room) startIdleWatch() {
+	if r.idleDone != nil {
+		return
+	}
+	r.idleDone = make(chan struct{})
+	go r.watchIdle(r.idleDone)
+}
+
+// does NOT lock mutexes
+func (r *Room) stopIdleWatch() {
+	if r.idleDone == nil {
+		return
+	}
+	close(r.idleDone)
+	r.idleDone = nil
+}
@@ -0,0 +1,67 @@
+package rooms
+
+import (
+	"fmt"
+	"mineOS/java"
+	"mineOS/versions"
+)
+
+// RequiredJava returns the major java version needed by the minecraft version of the room (0 if unknown)
+func (p *RoomProfile) RequiredJava() int {
+	major, err := versions.GetJavaVersion(p.Type, p.VersionID)
+	if err != nil {
+		fmt.Printf("unknown java version for %v %v: %v\n", p.Type, p.VersionID, err)
+		return 0
+	}
+	return major
+}
+
+// GetJava returns the java executable used to run the server:
+// the java-path of the profile if set, otherwise a compatible runtime from the registry
+func (p *RoomProfile) GetJava() (string, error) {
+	if p.JavaPath != "" {
+		return p.JavaPath, nil
+	}
+	rt, err := java.Select(p.RequiredJava())
+	if err != nil {
+		return "", err
+	}
+	return rt.Path, nil
+}
+
+// SetJavaPath sets the java runtime used by the room (an empty path selects one automatically)
+//
+// changes are applied on next start
+func (r *Room) SetJavaPath(path string) error {
+	if path != "" {
+		rt, err := java.Probe(path)
+		if err != nil {
+			return err
+		}
+		path = rt.Path
+	}
+	r.settingsmu.Lock()
+	r.Profile.JavaPath = path
+	r.settingsmu.Unlock()
+	r.saveProfile()
+	return nil
+}
+
+// GetJavaPath returns the java runtime set for the room (empty if it is selected automatically)
+func (r *Room) GetJavaPath() string {
+	r.settingsmu.Lock()
+	defer r.settingsmu.Unlock()
+	return r.Profile.JavaPath
+}
+
+// GetJava is RoomProfile.GetJava for a room in use
+func (r *Room) GetJava() (string, error) {
+	if path := r.GetJavaPath(); path != "" {
+		return path, nil
+	}
+	rt, err := java.Select(r.Profile.RequiredJava())
+	if err != nil {
+		return "", err
+	}
+	return rt.Path, nil
+}
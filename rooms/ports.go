@@ -0,0 +1,72 @@
+package rooms
+
+import (
+	"fmt"
+	"mineOS/properties"
+	"net"
+	"strconv"
+)
+
+var (
+	ErrPortInUse = fmt.Errorf("port already in use")
+)
+
+type Ports struct {
+	Server int `json:"server"`
+	Query  int `json:"query"`
+	Rcon   int `json:"rcon"`
+}
+
+func (p *Ports) List() []int {
+	return []int{p.Server, p.Query, p.Rcon}
+}
+
+// writeProperties sets the ports in the server.properties file of the room
+func (p *Ports) writeProperties(file string) error {
+	return properties.Update(file, func(props *properties.Properties) {
+		props.Set("server-port", strconv.Itoa(p.Server))
+		props.Set("query.port", strconv.Itoa(p.Query))
+		props.Set("rcon.port", strconv.Itoa(p.Rcon))
+	})
+}
+
+// checkAvailable returns ErrPortInUse if one of the ports used by the server
+// is already bound by another process
+//
+// query and rcon ports are only checked if enabled in server.properties
+func (p *Ports) checkAvailable(file string) error {
+	props, err := properties.Load(file)
+	if err != nil {
+		return err
+	}
+	err = checkTCP(p.Server)
+	if err != nil {
+		return err
+	}
+	if v, _ := props.Get("enable-query"); v == "true" {
+		err = checkUDP(p.Query)
+		if err != nil {
+			return err
+		}
+	}
+	if v, _ := props.Get("enable-rcon"); v == "true" {
+		return checkTCP(p.Rcon)
+	}
+	return nil
+}
+
+func checkTCP(port int) error {
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return fmt.Errorf("%w: %v/tcp", ErrPortInUse, port)
+	}
+	return l.Close()
+}
+
+func checkUDP(port int) error {
+	l, err := net.ListenPacket("udp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return fmt.Errorf("%w: %v/udp", ErrPortInUse, port)
+	}
+	return l.Close()
+}
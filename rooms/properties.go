@@ -0,0 +1,168 @@
+package rooms
+
+import (
+	"fmt"
+	"io"
+	"mineOS/properties"
+	"mineOS/servers"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidProperties = fmt.Errorf("invalid server properties")
+
+// PropertiesError lists the invalid values of a patch, by key
+type PropertiesError map[string]string
+
+func (e PropertiesError) Error() string {
+	var keys = make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var msgs = []string{}
+	for _, k := range keys {
+		msgs = append(msgs, e[k])
+	}
+	return fmt.Sprintf("%v: %v", ErrInvalidProperties, strings.Join(msgs, "; "))
+}
+
+func (e PropertiesError) Unwrap() error {
+	return ErrInvalidProperties
+}
+
+// PropertiesPatch reports what a patch changed
+type PropertiesPatch struct {
+	Changed []string `json:"changed"`
+	// changes applied to the running server with a command
+	AppliedLive []string `json:"applied-live"`
+	// changes that only apply once the server restarts (empty if the server is closed)
+	RestartRequired []string `json:"restart-required"`
+}
+
+// managedProperties returns the keys written by mineOS on every start, which cannot be patched
+func (p *RoomProfile) managedProperties() map[string]bool {
+	if p.Ports == nil {
+		return map[string]bool{}
+	}
+	return map[string]bool{
+		"server-port":   true,
+		"query.port":    true,
+		"rcon.port":     true,
+		"enable-rcon":   true,
+		"rcon.password": true,
+	}
+}
+
+// GetProperties returns server.properties with values converted to their json type (see properties.Typed)
+func (r *Room) GetProperties() (map[string]interface{}, error) {
+	r.propsmu.Lock()
+	defer r.propsmu.Unlock()
+	props, err := properties.Load(r.Profile.GetServerPropertiesFile())
+	if err != nil {
+		return nil, err
+	}
+	return props.Typed(), nil
+}
+
+// PatchProperties validates and writes changes (json values by key, nil removes the key) to server.properties,
+// comments and ordering of the file are kept
+//
+// if the server is running, changes that can be applied with a command are sent to the server;
+// returns a PropertiesError if a value is invalid (nothing is written)
+func (r *Room) PatchProperties(changes map[string]interface{}) (PropertiesPatch, error) {
+	var patch = PropertiesPatch{Changed: []string{}, AppliedLive: []string{}, RestartRequired: []string{}}
+	var keys = make([]string, 0, len(changes))
+	for k := range changes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var managed = r.Profile.managedProperties()
+	var values = map[string]*string{}
+	var errs = PropertiesError{}
+	for _, key := range keys {
+		if key == "" || strings.ContainsAny(key, "\r\n") {
+			errs[key] = fmt.Sprintf("invalid key %q", key)
+			continue
+		}
+		if managed[key] {
+			errs[key] = fmt.Sprintf("%v is managed by mineOS", key)
+			continue
+		}
+		if changes[key] == nil {
+			values[key] = nil
+			continue
+		}
+		value, err := properties.FromJSON(key, changes[key])
+		if err == nil {
+			value, err = properties.Normalize(key, value)
+		}
+		if err != nil {
+			errs[key] = err.Error()
+			continue
+		}
+		values[key] = &value
+	}
+	if len(errs) != 0 {
+		return patch, errs
+	}
+
+	r.propsmu.Lock()
+	defer r.propsmu.Unlock()
+	var changed = map[string]string{}
+	err := properties.Update(r.Profile.GetServerPropertiesFile(), func(props *properties.Properties) {
+		for _, key := range keys {
+			old, ok := props.Get(key)
+			if values[key] == nil {
+				if props.Delete(key) {
+					patch.Changed = append(patch.Changed, key)
+				}
+				continue
+			}
+			if ok && old == *values[key] {
+				continue
+			}
+			props.Set(key, *values[key])
+			patch.Changed = append(patch.Changed, key)
+			changed[key] = *values[key]
+		}
+	})
+	if err != nil {
+		return patch, err
+	}
+
+	var state = r.Srv.State
+	if state.IsClosed() {
+		return patch, nil
+	}
+	for _, key := range patch.Changed {
+		value, ok := changed[key] // removed keys need a restart
+		if state == servers.Running && ok && properties.LiveCommand(key, value) != "" {
+			_, _, err = r.SendCommand(properties.LiveCommand(key, value))
+			if err == nil {
+				patch.AppliedLive = append(patch.AppliedLive, key)
+				continue
+			}
+			fmt.Printf("failed to apply %v to server %v: %v\n", key, r.Profile.ID, err)
+		}
+		patch.RestartRequired = append(patch.RestartRequired, key)
+	}
+	return patch, nil
+}
+
+// ReplaceProperties replaces server.properties with the content of rd once validated
+//
+// returns a PropertiesError if a value is invalid (nothing is written)
+func (r *Room) ReplaceProperties(rd io.Reader) error {
+	props, err := properties.Parse(rd)
+	if err != nil {
+		return err
+	}
+	if errs := props.Validate(); errs != nil {
+		return PropertiesError(errs)
+	}
+	r.propsmu.Lock()
+	defer r.propsmu.Unlock()
+	return props.Save(r.Profile.GetServerPropertiesFile())
+}
@@ -0,0 +1,27 @@
+package rooms
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"mineOS/properties"
+)
+
+// enableRcon enables rcon in server.properties, generating a password if the profile has none
+//
+// returns true if a password was generated (the profile must then be saved)
+func (p *RoomProfile) enableRcon() (bool, error) {
+	var generated bool
+	if p.RconPassword == "" {
+		var buf = make([]byte, 16)
+		_, err := rand.Read(buf)
+		if err != nil {
+			return false, err
+		}
+		p.RconPassword = hex.EncodeToString(buf)
+		generated = true
+	}
+	return generated, properties.Update(p.GetServerPropertiesFile(), func(props *properties.Properties) {
+		props.Set("enable-rcon", "true")
+		props.Set("rcon.password", p.RconPassword)
+	})
+}
@@ -0,0 +1,159 @@
+package rooms
+
+import (
+	"fmt"
+	"mineOS/servers"
+	"time"
+
+	"github.com/Amqp-prtcl/snowflakes"
+)
+
+type RestartMode string
+
+const (
+	RestartNever     RestartMode = "never"
+	RestartOnFailure RestartMode = "on-failure" // only restarts after a crash
+	RestartAlways    RestartMode = "always"     // restarts unless stopped through mineOS
+)
+
+var (
+	ErrInvalidRestartPolicy = fmt.Errorf("invalid restart policy")
+)
+
+type RestartPolicy struct {
+	Mode RestartMode `json:"mode"`
+	// 0 means no limit
+	MaxRetries int `json:"max-retries"`
+	// delay before the first attempt, it is doubled after each failed attempt
+	BackoffSeconds    int `json:"backoff-seconds"`
+	MaxBackoffSeconds int `json:"max-backoff-seconds"`
+}
+
+func NewRestartPolicy() *RestartPolicy {
+	return &RestartPolicy{
+		Mode:              RestartNever,
+		MaxRetries:        3,
+		BackoffSeconds:    5,
+		MaxBackoffSeconds: 300,
+	}
+}
+
+func (p *RestartPolicy) Validate() error {
+	switch p.Mode {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidRestartPolicy, p.Mode)
+	}
+	if p.MaxRetries < 0 || p.BackoffSeconds < 0 || p.MaxBackoffSeconds < 0 {
+		return fmt.Errorf("%w: negative values", ErrInvalidRestartPolicy)
+	}
+	return nil
+}
+
+// shouldRestart reports whether a server that went to state st must be restarted
+func (p *RestartPolicy) shouldRestart(st servers.ServerState) bool {
+	switch p.Mode {
+	case RestartOnFailure:
+		return st == servers.Crashed
+	case RestartAlways:
+		return st.IsClosed()
+	}
+	return false
+}
+
+// backoff returns the delay before the attempt-th restart (starting at 1)
+func (p *RestartPolicy) backoff(attempt int) time.Duration {
+	var d = time.Duration(p.BackoffSeconds) * time.Second
+	var max = time.Duration(p.MaxBackoffSeconds) * time.Second
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
+
+type restartEvent struct {
+	ServerID snowflakes.ID `json:"server-id"`
+	Attempt  int           `json:"attempt"`
+	Delay    int64         `json:"delay-ms,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// onServerExit is called when the server process exited, st being the new state
+func (r *Room) onServerExit(st servers.ServerState) {
+	r.restartmu.Lock()
+	defer r.restartmu.Unlock()
+	if r.restartRequested {
+		r.restartRequested = false
+		r.stopRequested = false
+		r.retries = 0
+		go func() {
+			err := r.start()
+			if err != nil {
+				fmt.Printf("failed to restart server %v: %v\n", r.Profile.ID, err)
+				r.sendEvent("restart-failed", restartEvent{ServerID: r.Profile.ID, Error: err.Error()})
+			}
+		}()
+		return
+	}
+	if r.stopRequested || !r.Profile.Restart.shouldRestart(st) {
+		r.retries = 0
+		return
+	}
+	var max = r.Profile.Restart.MaxRetries
+	if max != 0 && r.retries >= max {
+		r.sendEvent("restart-given-up", restartEvent{ServerID: r.Profile.ID, Attempt: r.retries})
+		r.retries = 0
+		return
+	}
+	r.retries++
+	var attempt = r.retries
+	var delay = r.Profile.Restart.backoff(attempt)
+	r.sendEvent("restart-scheduled", restartEvent{ServerID: r.Profile.ID, Attempt: attempt, Delay: delay.Milliseconds()})
+	r.restartTimer = time.AfterFunc(delay, func() {
+		r.restartmu.Lock()
+		r.restartTimer = nil
+		r.restartmu.Unlock()
+
+		r.sendEvent("restart-attempt", restartEvent{ServerID: r.Profile.ID, Attempt: attempt})
+		err := r.start()
+		if err != nil {
+			fmt.Printf("failed to restart server %v (attempt %v): %v\n", r.Profile.ID, attempt, err)
+			r.sendEvent("restart-failed", restartEvent{ServerID: r.Profile.ID, Attempt: attempt, Error: err.Error()})
+			r.onServerExit(r.Srv.State)
+		}
+	})
+}
+
+// Restart stops the server and starts it again once it exited (a closed server is only started)
+func (r *Room) Restart() error {
+	if r.Srv.State.IsClosed() {
+		return r.Start()
+	}
+	r.restartmu.Lock()
+	r.restartRequested = true
+	r.stopReason = StopRestart
+	r.restartmu.Unlock()
+	err := r.Srv.Stop(false)
+	if err != nil {
+		r.restartmu.Lock()
+		r.restartRequested = false
+		r.restartmu.Unlock()
+	}
+	return err
+}
+
+// cancelRestart returns true if a pending restart was cancelled
+func (r *Room) cancelRestart() bool {
+	r.restartmu.Lock()
+	defer r.restartmu.Unlock()
+	r.retries = 0
+	if r.restartTimer == nil {
+		return false
+	}
+	r.restartTimer.Stop()
+	r.restartTimer = nil
+	return true
+}
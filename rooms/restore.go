@@ -0,0 +1,295 @@
+package rooms
+
+import (
+	"fmt"
+	"io"
+	"mineOS/archive"
+	"mineOS/downloads"
+	"mineOS/servers"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Amqp-prtcl/snowflakes"
+)
+
+var ErrInvalidBackup = fmt.Errorf("invalid backup")
+
+type RestoreResult struct {
+	// download ID of the backup of the server taken before restoring
+	Snapshot snowflakes.ID `json:"snapshot"`
+	Restored []string      `json:"restored"`
+}
+
+// RestoreDownload restores the server from a backup download, see Restore
+func (r *Room) RestoreDownload(id snowflakes.ID, paths []string) (RestoreResult, error) {
+	file, err := downloads.GetPath(id)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	return r.Restore(file, paths)
+}
+
+// RestoreUpload restores the server from the archive rd, see Restore
+func (r *Room) RestoreUpload(rd io.Reader, paths []string) (RestoreResult, error) {
+	tmp, err := r.tempDir("upload-")
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	defer os.RemoveAll(tmp)
+	var file = filepath.Join(tmp, "backup")
+	err = writeFile(file, rd)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	return r.Restore(file, paths)
+}
+
+// Restore replaces the files of the server by the ones of the archive file (server must be closed),
+// any format of the archive package is accepted
+//
+// paths are relative to the server folder (ex: "world"), only those are restored; if paths is empty,
+// the whole folder is restored and files that are not in the archive are removed. Files excluded by the backup
+// rules are never removed since backups do not contain them (they are replaced if the archive contains them).
+// A snapshot of the server is taken before any file is changed, its download ID is returned
+// (also on failure, once taken). If replacing the files fails, they are put back from the snapshot.
+//
+// if the archive contains a single folder holding server.properties, that folder is restored
+func (r *Room) Restore(file string, paths []string) (RestoreResult, error) {
+	var res = RestoreResult{Restored: []string{}}
+	var selected = []string{}
+	for _, p := range paths {
+		clean, err := cleanRestorePath(p)
+		if err != nil {
+			return res, err
+		}
+		selected = append(selected, clean)
+	}
+	paths = selected
+	var dir = filepath.Dir(r.Profile.JarPath)
+	err := r.Srv.Maintain(servers.Maintenance, func() error {
+		tmp, err := r.tempDir("restore-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		err = archive.Extract(file, tmp)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		root, err := backupRoot(tmp)
+		if err != nil {
+			return err
+		}
+		if root != tmp { // links were only checked against the whole archive
+			err = archive.CheckLinks(root)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+			}
+		}
+
+		var full = len(paths) == 0
+		if full {
+			entries, err := os.ReadDir(root)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				if e.Name() != servers.SupervisorFolder {
+					paths = append(paths, e.Name())
+				}
+			}
+			if len(paths) == 0 {
+				return fmt.Errorf("%w: archive is empty", ErrInvalidBackup)
+			}
+		}
+		for _, p := range paths {
+			if _, err := os.Lstat(filepath.Join(root, p)); err != nil {
+				return fmt.Errorf("%w: %v is not in the archive", ErrInvalidBackup, filepath.ToSlash(p))
+			}
+		}
+
+		res.Snapshot, err = r.backupFiles(false, "")
+		if err != nil {
+			return fmt.Errorf("failed to take a snapshot: %w", err)
+		}
+		var rules = r.backupRules()
+		var clear = paths
+		if full {
+			clear = []string{}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				if e.Name() != servers.SupervisorFolder {
+					clear = append(clear, e.Name())
+				}
+			}
+		}
+		err = replace(dir, root, clear, paths, rules, &res.Restored)
+		if err != nil {
+			rbErr := r.rollback(res.Snapshot, res.Restored, rules)
+			if rbErr != nil {
+				return fmt.Errorf("%w (rollback from the snapshot failed: %v)", err, rbErr)
+			}
+			res.Restored = []string{}
+			return fmt.Errorf("%w (rolled back from the snapshot)", err)
+		}
+		return nil
+	})
+	if err != nil && res.Snapshot != "" {
+		fmt.Printf("restore of server %v failed, snapshot %v was taken before: %v\n", r.Profile.ID, res.Snapshot, err)
+	}
+	return res, err
+}
+
+// replace removes the clear paths of dir, then moves the paths of root into dir (appending them to restored)
+func replace(dir string, root string, clear []string, paths []string, rules *archive.Rules, restored *[]string) error {
+	for _, p := range clear {
+		err := clearExcept(filepath.Join(dir, p), p, rules)
+		if err != nil {
+			return err
+		}
+	}
+	for _, p := range paths {
+		err := moveInto(filepath.Join(root, p), filepath.Join(dir, p))
+		if err != nil {
+			return err
+		}
+		*restored = append(*restored, filepath.ToSlash(p))
+	}
+	return nil
+}
+
+// rollback puts back the files of the snapshot after a failed restore, the paths already restored are
+// removed first; files of the path that failed that are not in the snapshot are left
+func (r *Room) rollback(snapshot snowflakes.ID, restored []string, rules *archive.Rules) error {
+	var dir = filepath.Dir(r.Profile.JarPath)
+	file, err := downloads.GetPath(snapshot)
+	if err != nil {
+		return err
+	}
+	tmp, err := r.tempDir("rollback-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	err = archive.Extract(file, tmp)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return err
+	}
+	var paths = []string{}
+	for _, e := range entries {
+		paths = append(paths, e.Name())
+	}
+	var clear = []string{}
+	for _, p := range restored {
+		clear = append(clear, filepath.FromSlash(p))
+	}
+	return replace(dir, tmp, clear, paths, rules, &[]string{})
+}
+
+// cleanRestorePath returns p as a clean relative path, refusing paths outside of the server folder
+func cleanRestorePath(p string) (string, error) {
+	var clean = filepath.Clean(filepath.FromSlash(p))
+	var first = strings.Split(clean, string(filepath.Separator))[0]
+	if p == "" || filepath.IsAbs(clean) || clean == "." || first == ".." || first == servers.SupervisorFolder {
+		return "", fmt.Errorf("%w: invalid path %q", ErrInvalidBackup, p)
+	}
+	return clean, nil
+}
+
+// clearExcept removes path (rel inside the server folder) except the entries excluded by rules,
+// folders holding excluded entries are kept
+func clearExcept(path string, rel string, rules *archive.Rules) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if rules.Excluded(filepath.ToSlash(rel), info.IsDir()) {
+		return nil
+	}
+	if !info.IsDir() {
+		return os.Remove(path)
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		err = clearExcept(filepath.Join(path, e.Name()), filepath.Join(rel, e.Name()), rules)
+		if err != nil {
+			return err
+		}
+	}
+	entries, err = os.ReadDir(path)
+	if err != nil || len(entries) != 0 {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// moveInto moves src to dst, merging folders that exist at both places (src wins for other entries)
+func moveInto(src string, dst string) error {
+	dstInfo, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		err = os.MkdirAll(filepath.Dir(dst), 0755)
+		if err != nil {
+			return err
+		}
+		return os.Rename(src, dst)
+	}
+	if err != nil {
+		return err
+	}
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if !srcInfo.IsDir() || !dstInfo.IsDir() {
+		err = os.RemoveAll(dst)
+		if err != nil {
+			return err
+		}
+		return os.Rename(src, dst)
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	// dst may be read-only, its mode is replaced by the one of src once merged
+	err = os.Chmod(dst, dstInfo.Mode().Perm()|0200)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		err = moveInto(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return os.Chmod(dst, srcInfo.Mode().Perm())
+}
+
+// backupRoot returns the folder of the extracted archive holding the server files
+func backupRoot(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		var sub = filepath.Join(dir, entries[0].Name())
+		if _, err := os.Stat(filepath.Join(sub, "server.properties")); err == nil {
+			return sub, nil
+		}
+	}
+	return dir, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"mineOS/globals"
+	"mineOS/servers"
 	"mineOS/versions"
 	"os"
 	"os/exec"
@@ -25,6 +26,23 @@ type RoomProfile struct {
 	Name      string              `json:"name"`
 	Emails    []string            `json:"emails"`
 	JarPath   string              `json:"jarpath"`
+	JVM       *servers.JVMConfig  `json:"jvm"`
+	Ports     *Ports              `json:"ports"` // assigned by the manager
+	Restart   *RestartPolicy      `json:"restart-policy"`
+
+	StopTimeouts *servers.StopTimeouts `json:"stop-timeouts"`
+	// number of console lines kept in memory (0 uses the global default)
+	ScrollbackSize int `json:"scrollback-size"`
+	// generated on first start
+	RconPassword string      `json:"rcon-password"`
+	Schedules    []*Schedule `json:"schedules"`
+
+	Limits *servers.Limits `json:"limits"`
+	Idle   *IdleShutdown   `json:"idle-shutdown"`
+	// java executable overriding the automatic selection ("" for automatic)
+	JavaPath string `json:"java-path"`
+	// gitignore-style patterns of the files left out of backups (see archive.Rules)
+	BackupRules []string `json:"backup-rules"`
 }
 
 // if file arg if empty, it will be fetch from config file
@@ -51,12 +69,23 @@ func SaveProfiles(file string, l []*RoomProfile) error {
 	if file == "" {
 		file = globals.ProfilesFiles.WarnGet()
 	}
-	f, err := os.Create(file)
+	// written next to the file then renamed, so that a crash cannot leave a truncated file
+	f, err := os.Create(file + ".tmp")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	return json.NewEncoder(f).Encode(l)
+	err = json.NewEncoder(f).Encode(l)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(file + ".tmp")
+		return err
+	}
+	return os.Rename(file+".tmp", file)
 }
 
 func GenerateRoom(name string, serverType versions.ServerType, versionID string) (*RoomProfile, error) {
@@ -69,6 +98,10 @@ func GenerateRoom(name string, serverType versions.ServerType, versionID string)
 		Type:      serverType,
 		VersionID: versionID,
 		Name:      name,
+		JVM:       servers.NewJVMConfig(),
+		Restart:   NewRestartPolicy(),
+
+		StopTimeouts: servers.NewStopTimeouts(),
 	}
 	// 1. generate id and create directory
 	profile.ID = ServersNode.NewID()
@@ -96,8 +129,12 @@ func GenerateRoom(name string, serverType versions.ServerType, versionID string)
 	}
 
 	// 3. agree to eula by running jar once and editing 'eula.txt'
+	javaPath, err := profile.GetJava()
+	if err != nil {
+		return nil, err
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	cmd := exec.CommandContext(ctx, "java", "-jar", profile.JarPath, "nogui")
+	cmd := exec.CommandContext(ctx, javaPath, "-jar", profile.JarPath, "nogui")
 	cmd.Dir = serverDir
 	err = cmd.Run()
 	cancel()
@@ -131,3 +168,14 @@ func GenerateRoom(name string, serverType versions.ServerType, versionID string)
 func (p *RoomProfile) GetServerPropertiesFile() string {
 	return filepath.Join(filepath.Dir(p.JarPath), "server.properties")
 }
+
+func (p *RoomProfile) GetScrollbackSize() int {
+	if p.ScrollbackSize > 0 {
+		return p.ScrollbackSize
+	}
+	return globals.ScrollbackSize.Get()
+}
+
+func (p *RoomProfile) GetLogsFolder() string {
+	return filepath.Join(filepath.Dir(p.JarPath), "logs")
+}
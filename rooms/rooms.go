@@ -3,9 +3,14 @@ package rooms
 import (
 	"encoding/json"
 	"fmt"
+	"mineOS/archive"
+	"mineOS/downloads"
 	"mineOS/emails"
+	"mineOS/properties"
 	"mineOS/servers"
+	"mineOS/status"
 	"mineOS/versions"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,83 +21,327 @@ import (
 type Room struct {
 	Srv     *servers.Server
 	Profile *RoomProfile
-	conns   []*websocket.Conn
+	conns   []*conn
 	mu      sync.Mutex
 	mailmu  sync.RWMutex
 
+	scrollback *scrollback
+	console    *consoleLog // nil while the server is closed
+
 	stateCallback func(*servers.Server)
+	lastState     servers.ServerState
+	reattached    bool // until the reattached server is back to running
+
+	restartmu     sync.Mutex
+	restartTimer  *time.Timer
+	retries       int
+	stopRequested bool
+	// the server is started again once it exited, see Restart
+	restartRequested bool
+	stopReason       StopReason // why the server was last stopped by mineOS
+
+	idleDone chan struct{} // closed to stop watchIdle
+
+	schedmu  sync.Mutex
+	accessmu sync.Mutex // access list files (whitelist, ops, bans)
+	propsmu  sync.Mutex // server.properties patches
+
+	backupmu  sync.Mutex
+	backingUp bool
+	rulesmu   sync.Mutex // backup rules
+
+	// settings of the profile (jvm config...), they are replaced and never modified in place
+	// so that the values returned by their getters can be used without locking
+	settingsmu sync.Mutex
 
-	cmds chan string
+	// called when the profile changed and must be persisted, must not block
+	OnProfileChange func()
 }
 
 func NewRoom(profile *RoomProfile, stateCallback func(*servers.Server)) *Room {
+	if profile.JVM == nil { // profiles saved before jvm configs existed
+		profile.JVM = servers.NewJVMConfig()
+	}
+	if profile.Restart == nil {
+		profile.Restart = NewRestartPolicy()
+	}
+	if profile.StopTimeouts == nil {
+		profile.StopTimeouts = servers.NewStopTimeouts()
+	}
+	if profile.Schedules == nil {
+		profile.Schedules = []*Schedule{}
+	}
+	if profile.Limits == nil {
+		profile.Limits = &servers.Limits{}
+	}
+	if profile.Idle == nil {
+		profile.Idle = &IdleShutdown{}
+	}
+	if profile.BackupRules == nil {
+		profile.BackupRules = DefaultBackupRules()
+	}
 	r := &Room{
 		Srv:           servers.NewServer(profile.JarPath),
 		Profile:       profile,
-		conns:         []*websocket.Conn{},
+		conns:         []*conn{},
 		mu:            sync.Mutex{},
 		mailmu:        sync.RWMutex{},
+		scrollback:    newScrollback(profile.GetScrollbackSize()),
 		stateCallback: stateCallback,
-		cmds:          make(chan string, 1),
+		lastState:     servers.Closed,
 	}
 
+	r.Srv.JVM = profile.JVM
+	r.Srv.StopTimeouts = profile.StopTimeouts
+	r.Srv.Limits = profile.Limits
 	r.Srv.OnLog = r.onLog
 	r.Srv.OnStateChange = r.onStateChange
+	r.Srv.OnEvent = r.onServerEvent
 	return r
 }
 
 func (r *Room) Start() error {
-	err := r.Srv.Start()
+	if !r.Srv.State.IsClosed() {
+		return servers.ErrNotClosed
+	}
+	r.cancelRestart()
+	r.restartmu.Lock()
+	r.stopRequested = false
+	r.restartmu.Unlock()
+	return r.start()
+}
+
+// start prepares the files of the server and starts it, the server cannot change state meanwhile
+func (r *Room) start() error {
+	var opened bool
+	err := r.Srv.Start(func() error {
+		r.restartmu.Lock()
+		r.stopReason = ""
+		r.restartmu.Unlock()
+		if r.Profile.Ports != nil {
+			var file = r.Profile.GetServerPropertiesFile()
+			err := r.Profile.Ports.writeProperties(file)
+			if err != nil {
+				return err
+			}
+			generated, err := r.Profile.enableRcon()
+			if err != nil {
+				return err
+			}
+			if generated {
+				r.saveProfile()
+			}
+			err = r.Profile.Ports.checkAvailable(file)
+			if err != nil {
+				return err
+			}
+		}
+		err := r.configureServer()
+		if err != nil {
+			return err
+		}
+		r.Srv.JVM = r.GetJVMConfig()
+		r.Srv.Limits = r.GetLimits()
+		r.Srv.Java, err = r.GetJava()
+		if err != nil {
+			return err
+		}
+		r.openConsole()
+		opened = true
+		return nil
+	})
+	if err != nil && opened {
+		r.console.close()
+		r.console = nil
+	}
+	return err
+}
+
+// Reattach attaches the room to a server left running by a previous mineOS process
+//
+// returns servers.ErrNotSupervised if there is none
+func (r *Room) Reattach() error {
+	if !r.Srv.State.IsClosed() {
+		return servers.ErrNotClosed
+	}
+	err := r.configureServer()
 	if err != nil {
 		return err
 	}
-	go r.cmdHandler()
+	r.openConsole()
+	r.reattached = true
+	err = r.Srv.Reattach()
+	if err != nil {
+		r.reattached = false
+		r.console.close()
+		r.console = nil
+	}
+	return err
+}
+
+// configureServer sets the addresses used to reach the server from its profile and server.properties
+func (r *Room) configureServer() error {
+	if r.Profile.Ports == nil {
+		return nil
+	}
+	props, err := properties.Load(r.Profile.GetServerPropertiesFile())
+	if err != nil {
+		return err
+	}
+	r.Srv.Host, _ = props.Get("server-ip")
+	r.Srv.Port = r.Profile.Ports.Server
+	r.Srv.RconPort = r.Profile.Ports.Rcon
+	r.Srv.RconPassword = r.Profile.RconPassword
+	// the running server uses the password of its properties, even if the profile was not saved since
+	if password, _ := props.Get("rcon.password"); password != "" {
+		r.Srv.RconPassword = password
+	}
 	return nil
 }
 
-func (r *Room) Stop() error {
-	return r.Srv.Stop()
+// saveProfile asks for the profile to be persisted after a change made by mineOS itself
+func (r *Room) saveProfile() {
+	if r.OnProfileChange != nil {
+		r.OnProfileChange()
+	}
+}
+
+// SavedProfile returns a copy of the profile that can be encoded while the room is in use
+func (r *Room) SavedProfile() *RoomProfile {
+	r.schedmu.Lock()
+	defer r.schedmu.Unlock()
+	r.mailmu.RLock()
+	defer r.mailmu.RUnlock()
+	r.rulesmu.Lock()
+	defer r.rulesmu.Unlock()
+	r.settingsmu.Lock()
+	defer r.settingsmu.Unlock()
+	var p = *r.Profile
+	p.Emails = append([]string{}, r.Profile.Emails...)
+	p.BackupRules = append([]string{}, r.Profile.BackupRules...)
+	p.Schedules = make([]*Schedule, 0, len(r.Profile.Schedules))
+	for _, s := range r.Profile.Schedules {
+		var c = s.copy()
+		p.Schedules = append(p.Schedules, &c)
+	}
+	return &p
 }
 
-func (r *Room) SendCommand(cmd string) {
-	if r.Srv.State != servers.Closed {
-		r.cmds <- cmd
+func (r *Room) openConsole() {
+	console, err := openConsoleLog(r.Profile.GetLogsFolder())
+	if err != nil {
+		fmt.Printf("failed to open console log of server %v: %v\n", r.Profile.ID, err)
 	}
+	r.console = console
 }
 
-func (r *Room) cmdHandler() {
-	for r.Srv.State != servers.Closed {
-		select {
-		case cmd := <-r.cmds:
-			r.Srv.SendCommand(cmd)
-		default:
-			continue
-		}
+// Stop also cancels any pending automatic restart
+//
+// see servers.Server.Stop for force
+func (r *Room) Stop(force bool) error {
+	return r.stop(force, StopUser)
+}
+
+func (r *Room) stop(force bool, reason StopReason) error {
+	r.restartmu.Lock()
+	r.stopRequested = true
+	r.stopReason = reason
+	r.restartmu.Unlock()
+	if r.cancelRestart() {
+		return nil
+	}
+	return r.Srv.Stop(force)
+}
+
+// SendCommand returns the output of cmd if it was sent through rcon (see servers.Server.Exec)
+func (r *Room) SendCommand(cmd string) (string, bool, error) {
+	return r.sendCommandTimeout(cmd, 0)
+}
+
+// sendCommandTimeout is SendCommand with the time given to the command to return through rcon (0 for the default)
+func (r *Room) sendCommandTimeout(cmd string, timeout time.Duration) (string, bool, error) {
+	var out string
+	var viaRcon bool
+	var err error
+	if timeout == 0 {
+		out, viaRcon, err = r.Srv.Exec(cmd)
+	} else {
+		out, viaRcon, err = r.Srv.ExecTimeout(cmd, timeout)
 	}
+	if err == nil && viaRcon {
+		r.sendEvent("cmd-output", struct {
+			ServerID snowflakes.ID `json:"server-id"`
+			Command  string        `json:"command"`
+			Output   string        `json:"output"`
+		}{r.Profile.ID, cmd, out})
+	}
+	return out, viaRcon, err
 }
 
-func (r *Room) AddConn(conn *websocket.Conn) {
+// AddConn replays the scrollback to conn before adding it to the room
+//
+// conn only receives console lines of level minLevel or above
+func (r *Room) AddConn(ws *websocket.Conn, minLevel servers.LogLevel) {
+	var c = &conn{Conn: ws, level: minLevel}
 	r.mu.Lock()
-	r.conns = append(r.conns, conn)
+	for _, rec := range r.scrollback.get() {
+		if !c.wants(rec.Level) {
+			continue
+		}
+		err := c.WriteMessage(websocket.TextMessage, []byte(rec.Raw))
+		if err == nil {
+			err = c.WriteMessage(websocket.TextMessage, r.logEvent(rec))
+		}
+		if err != nil {
+			r.mu.Unlock()
+			c.Close()
+			return
+		}
+	}
+	r.conns = append(r.conns, c)
 	r.mu.Unlock()
-	go func(c *websocket.Conn, ch chan string) {
+	go func(c *websocket.Conn) {
 		for {
 			_, data, err := c.ReadMessage()
 			if err != nil {
 				return
 			}
-			ch <- string(data)
+			_, _, err = r.SendCommand(string(data))
+			if err != nil {
+				fmt.Printf("failed to send command to server %v: %v\n", r.Profile.ID, err)
+			}
 		}
-	}(conn, r.cmds)
+	}(ws)
 }
 
-func (r *Room) onLog(_ *servers.Server, log string) {
-	cn := []int{}
+type conn struct {
+	*websocket.Conn
+	level servers.LogLevel
+}
+
+// an empty level means the message is not a console line
+func (c *conn) wants(level servers.LogLevel) bool {
+	return level == "" || c.level == "" || level.AtLeast(c.level)
+}
+
+// broadcast sends msg to all websocket connections, closing the ones that fail
+func (r *Room) broadcast(msg []byte) {
 	r.mu.Lock()
+	r.broadcastLocked(msg, "")
+	r.mu.Unlock()
+}
+
+// only sends msg to connections subscribed to level, see conn.wants
+//
+// does NOT lock mutexes
+func (r *Room) broadcastLocked(msg []byte, level servers.LogLevel) {
+	cn := []int{}
 	for i := range r.conns {
-		err := r.conns[i].WriteMessage(websocket.TextMessage, []byte(log))
+		if !r.conns[i].wants(level) {
+			continue
+		}
+		err := r.conns[i].WriteMessage(websocket.TextMessage, msg)
 		if err != nil {
-			fmt.Println(err)
 			cn = append(cn, i)
 		}
 	}
@@ -105,42 +354,120 @@ func (r *Room) onLog(_ *servers.Server, log string) {
 			r.conns = r.conns[0 : len(r.conns)-1]
 		}
 	}
-	r.mu.Unlock()
 }
 
-func (r *Room) onStateChange(_ *servers.Server) {
-	cn := []int{}
+type event struct {
+	Event string `json:"event"`
+	Data  string `json:"data"` // json encoded
+}
+
+func marshalEvent(name string, data interface{}) ([]byte, error) {
+	d, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(event{Event: name, Data: string(d)})
+}
+
+func (r *Room) sendEvent(name string, data interface{}) {
+	msg, err := marshalEvent(name, data)
+	if err != nil {
+		fmt.Printf("failed to marshal %v event: %v\n", name, err)
+		return
+	}
+	r.broadcast(msg)
+}
+
+func (r *Room) onServerEvent(_ *servers.Server, name string, data map[string]interface{}) {
+	data["server-id"] = r.Profile.ID
+	r.sendEvent(name, data)
+}
+
+func (r *Room) logEvent(rec *servers.LogRecord) []byte {
+	msg, _ := marshalEvent("log-record", struct {
+		ServerID snowflakes.ID `json:"server-id"`
+		*servers.LogRecord
+	}{r.Profile.ID, rec})
+	return msg
+}
+
+func (r *Room) onLog(_ *servers.Server, rec *servers.LogRecord) {
+	if r.Srv.IsReplaying() { // replayed lines are already in the console log and were already sent
+		r.mu.Lock()
+		r.scrollback.add(rec)
+		r.mu.Unlock()
+		return
+	}
+	r.console.writeRecord(rec)
 	r.mu.Lock()
-	for i := range r.conns {
-		err := r.conns[i].WriteMessage(websocket.TextMessage, []byte(r.Srv.State))
-		if err != nil {
-			cn = append(cn, i)
+	r.scrollback.add(rec)
+	r.broadcastLocked([]byte(rec.Raw), rec.Level)
+	r.broadcastLocked(r.logEvent(rec), rec.Level)
+	r.mu.Unlock()
+}
+
+// GetScrollback returns the latest console lines of level minLevel or above
+// from oldest to newest (an empty minLevel returns all lines)
+func (r *Room) GetScrollback(minLevel servers.LogLevel) []*servers.LogRecord {
+	var recs = []*servers.LogRecord{}
+	for _, rec := range r.scrollback.get() {
+		if minLevel == "" || rec.Level.AtLeast(minLevel) {
+			recs = append(recs, rec)
 		}
 	}
+	return recs
+}
 
-	if len(cn) != 0 {
-		for i := len(cn) - 1; i >= 0; i-- {
-			r.conns[cn[i]].Close()
-			r.conns[cn[i]] = nil
-			r.conns[cn[i]] = r.conns[len(r.conns)-1]
-			r.conns = r.conns[0 : len(r.conns)-1]
-		}
+func (r *Room) onStateChange(_ *servers.Server) {
+	var prev = r.lastState
+	r.lastState = r.Srv.State
+	if !r.reattached {
+		r.console.writeState(r.Srv.State)
 	}
-	r.mu.Unlock()
+	r.broadcast([]byte(r.Srv.State))
 	if r.stateCallback != nil {
 		r.stateCallback(r.Srv)
 	}
+	// states set around zipping and maintenance are not process exits
+	var exited = prev == servers.Starting || prev == servers.Running || prev == servers.Stopping
+	if exited && r.Srv.State.IsClosed() {
+		r.stopIdleWatch()
+		r.console.close()
+		r.console = nil
+		r.reattached = false
+	}
 	switch r.Srv.State {
 	case servers.Running:
+		r.startIdleWatch()
+		r.restartmu.Lock()
+		r.retries = 0
+		r.restartmu.Unlock()
+		if r.reattached { // players did not see any restart
+			r.reattached = false
+			return
+		}
 		err := r.sendRunningEmail()
 		if err != nil {
 			fmt.Printf("Error sending running email(s): %v\n", err)
 		}
 	case servers.Closed:
+		if !exited {
+			return
+		}
 		err := r.sendCloseMail()
 		if err != nil {
 			fmt.Printf("Error sending closing email(s): %v\n", err)
 		}
+		r.onServerExit(servers.Closed)
+	case servers.Crashed:
+		if !exited {
+			return
+		}
+		err := r.sendCrashMail()
+		if err != nil {
+			fmt.Printf("Error sending crash email(s): %v\n", err)
+		}
+		r.onServerExit(servers.Crashed)
 	}
 }
 
@@ -155,11 +482,43 @@ func (r *Room) sendRunningEmail() error {
 func (r *Room) sendCloseMail() error {
 	var subject = fmt.Sprintf("MineOS: Server %s (id: %s) Closed.", r.Profile.Name, r.Profile.ID.String())
 	var body = fmt.Sprintf("Server %s (id: %s) has closed if this is unintentional or unexpected please log in in order to resolve possible issue.", r.Profile.Name, r.Profile.ID.String())
+	if r.GetStopReason() == StopIdle {
+		subject = fmt.Sprintf("MineOS: Server %s (id: %s) Stopped (idle).", r.Profile.Name, r.Profile.ID.String())
+		body = fmt.Sprintf("Server %s (id: %s) has been stopped as no player was online for %v minutes.", r.Profile.Name, r.Profile.ID.String(), r.GetIdleShutdown().Minutes)
+	}
 	r.mailmu.RLock()
 	defer r.mailmu.RUnlock()
 	return emails.SendEmail(r.Profile.Emails, subject, body)
 }
 
+func (r *Room) sendCrashMail() error {
+	var subject = fmt.Sprintf("MineOS: Server %s (id: %s) Crashed.", r.Profile.Name, r.Profile.ID.String())
+	var body = fmt.Sprintf("Server %s (id: %s) has crashed (%v). Please log in in order to resolve possible issue.", r.Profile.Name, r.Profile.ID.String(), r.Srv.ExitErr)
+	if lines := r.lastStderr(crashMailLines); len(lines) != 0 {
+		body += "\n\nLast errors:\n" + strings.Join(lines, "")
+	}
+	r.mailmu.RLock()
+	defer r.mailmu.RUnlock()
+	return emails.SendEmail(r.Profile.Emails, subject, body)
+}
+
+// number of stderr lines included in crash emails
+const crashMailLines = 30
+
+// lastStderr returns the last n stderr lines of the scrollback
+func (r *Room) lastStderr(n int) []string {
+	var lines = []string{}
+	for _, rec := range r.scrollback.get() {
+		if rec.Stream == servers.Stderr {
+			lines = append(lines, rec.Raw)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
 func (r *Room) AddEmail(email ...string) {
 	r.mailmu.Lock()
 	defer r.mailmu.Unlock()
@@ -187,15 +546,26 @@ func (r *Room) AddEmail(email ...string) {
 }
 
 func (r *Room) MarshalRoomInfo() []byte {
+	var st = r.Srv.GetStatus() // nil if not running or not answering
 	r.mailmu.RLock()
 	defer r.mailmu.RUnlock()
 	var info = struct {
-		ID      snowflakes.ID       `json:"id"`
-		Name    string              `json:"name"`
-		Emails  []string            `json:"emails"`
-		SrvType versions.ServerType `json:"server-type"`
-		VrsID   string              `json:"version-id"`
-		State   servers.ServerState `json:"state"`
+		ID      snowflakes.ID         `json:"id"`
+		Name    string                `json:"name"`
+		Emails  []string              `json:"emails"`
+		SrvType versions.ServerType   `json:"server-type"`
+		VrsID   string                `json:"version-id"`
+		State   servers.ServerState   `json:"state"`
+		JVM     *servers.JVMConfig    `json:"jvm"`
+		Ports   *Ports                `json:"ports"`
+		Restart *RestartPolicy        `json:"restart-policy"`
+		Stop    *servers.StopTimeouts `json:"stop-timeouts"`
+		Limits  *servers.Limits       `json:"limits"`
+		Idle    *IdleShutdown         `json:"idle-shutdown"`
+		Reason  StopReason            `json:"stop-reason"`
+		Players int                   `json:"players"`
+		Status  *status.Status        `json:"status"`
+		Metrics *servers.Metrics      `json:"metrics"`
 	}{
 		ID:      r.Profile.ID,
 		Name:    r.Profile.Name,
@@ -203,11 +573,127 @@ func (r *Room) MarshalRoomInfo() []byte {
 		SrvType: r.Profile.Type,
 		VrsID:   r.Profile.VersionID,
 		State:   r.Srv.State,
+		JVM:     r.GetJVMConfig(),
+		Ports:   r.Profile.Ports,
+		Restart: r.Profile.Restart,
+		Stop:    r.GetStopTimeouts(),
+		Limits:  r.GetLimits(),
+		Idle:    r.GetIdleShutdown(),
+		Reason:  r.GetStopReason(),
+		Players: r.Srv.PlayerCount(),
+		Status:  st,
+		Metrics: r.Srv.GetMetrics(),
 	}
 	data, _ := json.Marshal(info)
 	return data
 }
 
+// SetJVMConfig validates cfg before replacing the room's jvm config
+//
+// changes are applied on next start
+func (r *Room) SetJVMConfig(cfg *servers.JVMConfig) error {
+	err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+	if cfg.Presets == nil {
+		cfg.Presets = []string{}
+	}
+	if cfg.Flags == nil {
+		cfg.Flags = []string{}
+	}
+	if cfg.ServerArgs == nil {
+		cfg.ServerArgs = []string{}
+	}
+	var c = *cfg
+	r.settingsmu.Lock()
+	r.Profile.JVM = &c
+	r.settingsmu.Unlock()
+	r.saveProfile()
+	return nil
+}
+
+// GetJVMConfig returns the jvm config of the room, it must not be modified
+func (r *Room) GetJVMConfig() *servers.JVMConfig {
+	r.settingsmu.Lock()
+	defer r.settingsmu.Unlock()
+	return r.Profile.JVM
+}
+
+func (r *Room) SetRestartPolicy(p *RestartPolicy) error {
+	err := p.Validate()
+	if err != nil {
+		return err
+	}
+	*r.Profile.Restart = *p
+	return nil
+}
+
+func (r *Room) SetStopTimeouts(t *servers.StopTimeouts) error {
+	err := t.Validate()
+	if err != nil {
+		return err
+	}
+	var c = *t
+	r.settingsmu.Lock()
+	r.Profile.StopTimeouts = &c
+	r.settingsmu.Unlock()
+	r.Srv.SetStopTimeouts(&c)
+	r.saveProfile()
+	return nil
+}
+
+// GetStopTimeouts returns the stop timeouts of the room, they must not be modified
+func (r *Room) GetStopTimeouts() *servers.StopTimeouts {
+	r.settingsmu.Lock()
+	defer r.settingsmu.Unlock()
+	return r.Profile.StopTimeouts
+}
+
+// GetStopReason returns why mineOS last stopped the server (empty if it was not stopped since its last start)
+func (r *Room) GetStopReason() StopReason {
+	r.restartmu.Lock()
+	defer r.restartmu.Unlock()
+	return r.stopReason
+}
+
+// SetLimits validates l before replacing the room's resource limits
+//
+// changes are applied on next start
+func (r *Room) SetLimits(l *servers.Limits) error {
+	err := l.Validate()
+	if err != nil {
+		return err
+	}
+	var c = *l
+	r.settingsmu.Lock()
+	r.Profile.Limits = &c
+	r.settingsmu.Unlock()
+	r.saveProfile()
+	return nil
+}
+
+// GetLimits returns the resource limits of the room, they must not be modified
+func (r *Room) GetLimits() *servers.Limits {
+	r.settingsmu.Lock()
+	defer r.settingsmu.Unlock()
+	return r.Profile.Limits
+}
+
 func (r *Room) Zip() (snowflakes.ID, error) {
-	return r.Srv.Zip(fmt.Sprintf("backup-server-%s-%v", r.Profile.Name, time.Now().UnixMilli()))
+	return r.Srv.Zip(fmt.Sprintf("backup-server-%s-%v", r.Profile.Name, time.Now().UnixMilli()), r.backupRules())
+}
+
+// ZipLogs packages the logs folder of the room as a download
+func (r *Room) ZipLogs() (snowflakes.ID, error) {
+	wr, id, err := downloads.NewFile(fmt.Sprintf("logs-server-%s-%v.zip", r.Profile.Name, time.Now().UnixMilli()), 7*24*time.Hour)
+	if err != nil {
+		return id, err
+	}
+	err = archive.Archive(archive.Zip, r.Profile.GetLogsFolder(), wr, archive.Options{})
+	if err != nil {
+		wr.Abort()
+		return "", err
+	}
+	return id, wr.Close()
 }
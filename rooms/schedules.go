@@ -0,0 +1,338 @@
+package rooms
+
+import (
+	"fmt"
+	"mineOS/archive"
+	"mineOS/cron"
+	"time"
+
+	"github.com/Amqp-prtcl/snowflakes"
+)
+
+type ScheduleAction string
+
+const (
+	ActionCommand ScheduleAction = "command" // sends Command to the console
+	ActionRestart ScheduleAction = "restart"
+	ActionBackup  ScheduleAction = "backup"
+	ActionStart   ScheduleAction = "start"
+	ActionStop    ScheduleAction = "stop"
+)
+
+// MissedRunPolicy tells what to do with runs missed while mineOS was down
+type MissedRunPolicy string
+
+const (
+	MissedSkip    MissedRunPolicy = "skip"
+	MissedRunOnce MissedRunPolicy = "run-once" // missed runs are caught up with a single run
+)
+
+type RunStatus string
+
+const (
+	RunSuccess RunStatus = "success"
+	RunFailed  RunStatus = "failed"
+	RunMissed  RunStatus = "missed"
+)
+
+// number of runs kept in the history of each schedule
+const scheduleHistorySize = 50
+
+var (
+	schedulesNode = snowflakes.NewNode(4)
+
+	ErrInvalidSchedule  = fmt.Errorf("invalid schedule")
+	ErrScheduleNotFound = fmt.Errorf("schedule not found")
+)
+
+type Schedule struct {
+	ID        snowflakes.ID   `json:"id"`
+	Cron      string          `json:"cron"`
+	Action    ScheduleAction  `json:"action"`
+	Command   string          `json:"command,omitempty"` // only for the command action
+	Format    archive.Format  `json:"format,omitempty"`  // only for the backup action, backup-format setting if empty
+	Enabled   bool            `json:"enabled"`
+	MissedRun MissedRunPolicy `json:"missed-run"`
+	CreatedAt time.Time       `json:"created-at"`
+	// last scheduled time that was handled (run or missed)
+	LastRun time.Time     `json:"last-run"`
+	NextRun time.Time     `json:"next-run"` // zero if disabled
+	History []ScheduleRun `json:"history"`
+
+	timer *time.Timer
+}
+
+type ScheduleRun struct {
+	Scheduled time.Time `json:"scheduled"`
+	Time      time.Time `json:"time"`
+	Status    RunStatus `json:"status"`
+	Missed    int       `json:"missed,omitempty"` // number of runs missed while mineOS was down
+	Output    string    `json:"output,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func (s *Schedule) Validate() error {
+	_, err := cron.Parse(s.Cron)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	switch s.Action {
+	case ActionCommand:
+		if s.Command == "" {
+			return fmt.Errorf("%w: missing command", ErrInvalidSchedule)
+		}
+	case ActionBackup:
+		if s.Format != "" && !s.Format.Valid() {
+			return fmt.Errorf("%w: unknown archive format %q", ErrInvalidSchedule, s.Format)
+		}
+	case ActionRestart, ActionStart, ActionStop:
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidSchedule, s.Action)
+	}
+	switch s.MissedRun {
+	case MissedSkip, MissedRunOnce:
+	default:
+		return fmt.Errorf("%w: unknown missed-run policy %q", ErrInvalidSchedule, s.MissedRun)
+	}
+	return nil
+}
+
+func (s *Schedule) addRun(run ScheduleRun) {
+	s.History = append(s.History, run)
+	if len(s.History) > scheduleHistorySize {
+		s.History = s.History[len(s.History)-scheduleHistorySize:]
+	}
+}
+
+// StartSchedules handles the runs missed since the schedules last ran, then starts them
+//
+// must be called once, after reattaching to a running server
+func (r *Room) StartSchedules() {
+	r.schedmu.Lock()
+	defer r.schedmu.Unlock()
+	var now = time.Now()
+	var changed bool
+	for _, s := range r.Profile.Schedules {
+		if !s.Enabled {
+			continue
+		}
+		c, err := cron.Parse(s.Cron)
+		if err != nil {
+			fmt.Printf("invalid schedule %v of server %v: %v\n", s.ID, r.Profile.ID, err)
+			continue
+		}
+		var last = s.LastRun
+		if last.IsZero() {
+			last = s.CreatedAt
+		}
+		var missed int
+		var scheduled time.Time
+		for next := c.Next(last); !next.IsZero() && next.Before(now); next = c.Next(next) {
+			missed++
+			scheduled = next
+		}
+		if missed != 0 {
+			changed = true
+			s.LastRun = scheduled
+			if s.MissedRun == MissedRunOnce {
+				go r.runSchedule(s.ID, scheduled, missed)
+			} else {
+				s.addRun(ScheduleRun{Scheduled: scheduled, Time: now, Status: RunMissed, Missed: missed})
+			}
+		}
+		r.armSchedule(s, c)
+	}
+	if changed {
+		r.saveProfile()
+	}
+}
+
+// armSchedule sets the timer of s for its next run
+//
+// does NOT lock mutexes
+func (r *Room) armSchedule(s *Schedule, c *cron.Schedule) {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.NextRun = time.Time{}
+	if !s.Enabled {
+		return
+	}
+	var next = c.Next(time.Now())
+	if next.IsZero() {
+		return
+	}
+	s.NextRun = next
+	var id = s.ID
+	s.timer = time.AfterFunc(time.Until(next), func() {
+		r.runSchedule(id, next, 0)
+		r.schedmu.Lock()
+		defer r.schedmu.Unlock()
+		if s, ok := r.findSchedule(id); ok && s.NextRun.Equal(next) {
+			r.armSchedule(s, c)
+		}
+	})
+}
+
+// runSchedule executes the action of the schedule id for the run planned at scheduled
+func (r *Room) runSchedule(id snowflakes.ID, scheduled time.Time, missed int) {
+	r.schedmu.Lock()
+	s, ok := r.findSchedule(id)
+	if !ok {
+		r.schedmu.Unlock()
+		return
+	}
+	var action, command, format = s.Action, s.Command, s.Format
+	r.schedmu.Unlock()
+
+	var run = ScheduleRun{Scheduled: scheduled, Time: time.Now(), Status: RunSuccess, Missed: missed}
+	var err error
+	switch action {
+	case ActionCommand:
+		run.Output, _, err = r.SendCommand(command)
+	case ActionRestart:
+		err = r.Restart()
+	case ActionBackup:
+		var dl snowflakes.ID
+		dl, err = r.Backup(format)
+		run.Output = string(dl)
+	case ActionStart:
+		err = r.Start()
+	case ActionStop:
+		err = r.stop(false, StopSchedule)
+	}
+	if err != nil {
+		fmt.Printf("scheduled %v of server %v failed: %v\n", action, r.Profile.ID, err)
+		run.Status = RunFailed
+		run.Error = err.Error()
+	}
+
+	r.schedmu.Lock()
+	if s, ok := r.findSchedule(id); ok {
+		s.LastRun = scheduled
+		s.addRun(run)
+	}
+	r.schedmu.Unlock()
+	r.saveProfile()
+	r.sendEvent("schedule-run", struct {
+		ServerID   snowflakes.ID  `json:"server-id"`
+		ScheduleID snowflakes.ID  `json:"schedule-id"`
+		Action     ScheduleAction `json:"action"`
+		ScheduleRun
+	}{r.Profile.ID, id, action, run})
+}
+
+// does NOT lock mutexes
+func (r *Room) findSchedule(id snowflakes.ID) (*Schedule, bool) {
+	for _, s := range r.Profile.Schedules {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// GetSchedules returns copies of the schedules of the room
+func (r *Room) GetSchedules() []Schedule {
+	r.schedmu.Lock()
+	defer r.schedmu.Unlock()
+	var l = []Schedule{}
+	for _, s := range r.Profile.Schedules {
+		l = append(l, s.copy())
+	}
+	return l
+}
+
+func (r *Room) GetSchedule(id snowflakes.ID) (Schedule, error) {
+	r.schedmu.Lock()
+	defer r.schedmu.Unlock()
+	s, ok := r.findSchedule(id)
+	if !ok {
+		return Schedule{}, ErrScheduleNotFound
+	}
+	return s.copy(), nil
+}
+
+func (s *Schedule) copy() Schedule {
+	var c = *s
+	c.History = append([]ScheduleRun{}, s.History...)
+	c.timer = nil
+	return c
+}
+
+// AddSchedule validates s and starts it, an empty missed-run policy defaults to skip
+func (r *Room) AddSchedule(s *Schedule) (snowflakes.ID, error) {
+	if s.MissedRun == "" {
+		s.MissedRun = MissedSkip
+	}
+	err := s.Validate()
+	if err != nil {
+		return "", err
+	}
+	c, _ := cron.Parse(s.Cron)
+	var sched = &Schedule{
+		ID:        schedulesNode.NewID(),
+		Cron:      s.Cron,
+		Action:    s.Action,
+		Command:   s.Command,
+		Format:    s.Format,
+		Enabled:   s.Enabled,
+		MissedRun: s.MissedRun,
+		CreatedAt: time.Now(),
+		History:   []ScheduleRun{},
+	}
+	r.schedmu.Lock()
+	defer r.schedmu.Unlock()
+	r.Profile.Schedules = append(r.Profile.Schedules, sched)
+	r.armSchedule(sched, c)
+	r.saveProfile()
+	return sched.ID, nil
+}
+
+// UpdateSchedule replaces the settings of the schedule id, its history is kept
+func (r *Room) UpdateSchedule(id snowflakes.ID, s *Schedule) error {
+	if s.MissedRun == "" {
+		s.MissedRun = MissedSkip
+	}
+	err := s.Validate()
+	if err != nil {
+		return err
+	}
+	c, _ := cron.Parse(s.Cron)
+	r.schedmu.Lock()
+	defer r.schedmu.Unlock()
+	sched, ok := r.findSchedule(id)
+	if !ok {
+		return ErrScheduleNotFound
+	}
+	if !sched.Enabled && s.Enabled {
+		sched.LastRun = time.Now() // runs missed while disabled are not missed runs
+	}
+	sched.Cron = s.Cron
+	sched.Action = s.Action
+	sched.Command = s.Command
+	sched.Format = s.Format
+	sched.Enabled = s.Enabled
+	sched.MissedRun = s.MissedRun
+	r.armSchedule(sched, c)
+	r.saveProfile()
+	return nil
+}
+
+func (r *Room) RemoveSchedule(id snowflakes.ID) error {
+	r.schedmu.Lock()
+	defer r.schedmu.Unlock()
+	for i, s := range r.Profile.Schedules {
+		if s.ID != id {
+			continue
+		}
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		r.Profile.Schedules = append(r.Profile.Schedules[:i], r.Profile.Schedules[i+1:]...)
+		r.saveProfile()
+		return nil
+	}
+	return ErrScheduleNotFound
+}
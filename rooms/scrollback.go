@@ -0,0 +1,46 @@
+package rooms
+
+import (
+	"mineOS/servers"
+	"sync"
+)
+
+// scrollback is a bounded ring buffer of the latest console lines of a room
+type scrollback struct {
+	lines []*servers.LogRecord
+	start int
+	count int
+	mu    sync.Mutex
+}
+
+func newScrollback(size int) *scrollback {
+	if size <= 0 {
+		size = 1
+	}
+	return &scrollback{
+		lines: make([]*servers.LogRecord, size),
+	}
+}
+
+func (b *scrollback) add(line *servers.LogRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var i = (b.start + b.count) % len(b.lines)
+	b.lines[i] = line
+	if b.count < len(b.lines) {
+		b.count++
+	} else {
+		b.start = (b.start + 1) % len(b.lines)
+	}
+}
+
+// get returns the stored lines from oldest to newest
+func (b *scrollback) get() []*servers.LogRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var lines = make([]*servers.LogRecord, 0, b.count)
+	for i := 0; i < b.count; i++ {
+		lines = append(lines, b.lines[(b.start+i)%len(b.lines)])
+	}
+	return lines
+}
@@ -0,0 +1,328 @@
+package rooms
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"mineOS/archive"
+	"mineOS/properties"
+	"mineOS/servers"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Amqp-prtcl/snowflakes"
+)
+
+var (
+	ErrWorldNotFound = fmt.Errorf("world not found")
+	ErrWorldExists   = fmt.Errorf("world already exists")
+	ErrInvalidWorld  = fmt.Errorf("invalid world")
+)
+
+// World is a folder of the server containing a level.dat file
+type World struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`     // bytes
+	Active   bool      `json:"active"`   // level-name of the server
+	Modified time.Time `json:"modified"` // of level.dat
+}
+
+func (r *Room) worldPath(name string) string {
+	return filepath.Join(filepath.Dir(r.Profile.JarPath), name)
+}
+
+// activeWorld returns the level-name of the server
+func (r *Room) activeWorld() (string, error) {
+	props, err := properties.Load(r.Profile.GetServerPropertiesFile())
+	if err != nil {
+		return "", err
+	}
+	if name, _ := props.Get("level-name"); name != "" {
+		return name, nil
+	}
+	return "world", nil
+}
+
+func validWorldName(name string) error {
+	_, err := properties.Normalize("level-name", name)
+	if err != nil || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w: invalid name %q", ErrInvalidWorld, name)
+	}
+	return nil
+}
+
+// isWorld reports whether dir contains a level.dat file
+func isWorld(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "level.dat"))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// ListWorlds returns the worlds of the server, by name
+func (r *Room) ListWorlds() ([]World, error) {
+	active, err := r.activeWorld()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Dir(r.Profile.JarPath))
+	if err != nil {
+		return nil, err
+	}
+	var worlds = []World{}
+	for _, e := range entries {
+		var path = r.worldPath(e.Name())
+		if !e.IsDir() || !isWorld(path) {
+			continue
+		}
+		var w = World{Name: e.Name(), Active: e.Name() == active}
+		if info, err := os.Stat(filepath.Join(path, "level.dat")); err == nil {
+			w.Modified = info.ModTime()
+		}
+		w.Size, err = folderSize(path)
+		if err != nil {
+			return nil, err
+		}
+		worlds = append(worlds, w)
+	}
+	sort.Slice(worlds, func(i, j int) bool {
+		return worlds[i].Name < worlds[j].Name
+	})
+	return worlds, nil
+}
+
+func folderSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// removeWorld removes the world folder and the dimension folders made by bukkit based servers (name_nether, name_the_end)
+func (r *Room) removeWorld(name string) error {
+	for _, dir := range []string{name, name + "_nether", name + "_the_end"} {
+		var path = r.worldPath(dir)
+		if dir != name && !isWorld(path) {
+			continue
+		}
+		err := os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tempDir creates a temporary folder inside the server folder (so that its content can be moved in place),
+// the caller must remove it
+func (r *Room) tempDir(prefix string) (string, error) {
+	var folder = filepath.Join(filepath.Dir(r.Profile.JarPath), servers.SupervisorFolder)
+	err := os.MkdirAll(folder, 0755)
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(folder, prefix)
+}
+
+func writeFile(path string, rd io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, rd)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// UploadWorld extracts the world contained in the archive rd (any format of the archive package) as name
+//
+// if name is empty, the name of the folder containing level.dat in the archive is used (the name is returned);
+// if replace is true, an existing world is replaced (server must be closed, a backup is taken first)
+// and the download ID of the backup is returned
+func (r *Room) UploadWorld(rd io.Reader, name string, replace bool) (string, snowflakes.ID, error) {
+	if name != "" {
+		if err := validWorldName(name); err != nil {
+			return "", "", err
+		}
+	}
+	tmp, err := r.tempDir("upload-")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(tmp)
+	var upload = filepath.Join(tmp, "world-archive")
+	err = writeFile(upload, rd)
+	if err != nil {
+		return "", "", err
+	}
+	var extracted = filepath.Join(tmp, "world")
+	err = archive.Extract(upload, extracted)
+	os.Remove(upload)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidWorld, err)
+	}
+	root, err := findWorldRoot(extracted)
+	if err != nil {
+		return "", "", err
+	}
+	if root != extracted { // links were only checked against the whole archive
+		err = archive.CheckLinks(root)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrInvalidWorld, err)
+		}
+	}
+	if name == "" {
+		if root == extracted {
+			return "", "", fmt.Errorf("%w: missing name", ErrInvalidWorld)
+		}
+		name = filepath.Base(root)
+		if err = validWorldName(name); err != nil {
+			return "", "", err
+		}
+	}
+
+	var path = r.worldPath(name)
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return name, "", os.Rename(root, path)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if !replace {
+		return "", "", ErrWorldExists
+	}
+	var backup snowflakes.ID
+	err = r.Srv.Maintain(servers.Maintenance, func() error {
+		if !isWorld(path) {
+			return fmt.Errorf("%w: %v is not a world folder", ErrInvalidWorld, name)
+		}
+		backup, err = r.backupFiles(false, "")
+		if err != nil {
+			return err
+		}
+		err = os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+		return os.Rename(root, path)
+	})
+	return name, backup, err
+}
+
+// findWorldRoot returns the shallowest folder of dir containing level.dat
+func findWorldRoot(dir string) (string, error) {
+	var root string
+	var depth = -1
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() != "level.dat" || !d.Type().IsRegular() {
+			return nil
+		}
+		var folder = filepath.Dir(path)
+		var n = strings.Count(folder, string(filepath.Separator))
+		if depth == -1 || n < depth {
+			root, depth = folder, n
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if root == "" {
+		return "", fmt.Errorf("%w: archive does not contain level.dat", ErrInvalidWorld)
+	}
+	return root, nil
+}
+
+// DeleteWorld removes a world (server must be closed), a backup is taken first and its download ID is returned
+func (r *Room) DeleteWorld(name string) (snowflakes.ID, error) {
+	err := validWorldName(name)
+	if err != nil {
+		return "", err
+	}
+	var backup snowflakes.ID
+	err = r.Srv.Maintain(servers.Maintenance, func() error {
+		if !isWorld(r.worldPath(name)) {
+			return ErrWorldNotFound
+		}
+		backup, err = r.backupFiles(false, "")
+		if err != nil {
+			return err
+		}
+		return r.removeWorld(name)
+	})
+	return backup, err
+}
+
+// ResetWorld removes a world so that the server generates it again on its next start (server must be closed)
+//
+// if seed is not nil, it is set as level-seed; if the world existed, a backup is taken first
+// and its download ID is returned
+func (r *Room) ResetWorld(name string, seed *string) (snowflakes.ID, error) {
+	err := validWorldName(name)
+	if err != nil {
+		return "", err
+	}
+	if seed != nil {
+		_, err = properties.Normalize("level-seed", *seed)
+		if err != nil {
+			return "", err
+		}
+	}
+	var backup snowflakes.ID
+	err = r.Srv.Maintain(servers.Maintenance, func() error {
+		var path = r.worldPath(name)
+		if _, err := os.Stat(path); err == nil {
+			if !isWorld(path) {
+				return fmt.Errorf("%w: %v is not a world folder", ErrInvalidWorld, name)
+			}
+			backup, err = r.backupFiles(false, "")
+			if err != nil {
+				return err
+			}
+			err = r.removeWorld(name)
+			if err != nil {
+				return err
+			}
+		}
+		if seed == nil {
+			return nil
+		}
+		r.propsmu.Lock()
+		defer r.propsmu.Unlock()
+		return properties.Update(r.Profile.GetServerPropertiesFile(), func(props *properties.Properties) {
+			props.Set("level-seed", *seed)
+		})
+	})
+	return backup, err
+}
+
+// SwitchWorld sets the level-name of the server to an existing world, see PatchProperties
+func (r *Room) SwitchWorld(name string) (PropertiesPatch, error) {
+	err := validWorldName(name)
+	if err != nil {
+		return PropertiesPatch{}, err
+	}
+	if !isWorld(r.worldPath(name)) {
+		return PropertiesPatch{}, ErrWorldNotFound
+	}
+	return r.PatchProperties(map[string]interface{}{"level-name": name})
+}
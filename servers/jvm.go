@@ -0,0 +1,161 @@
+package servers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// heap sizes as accepted by -Xms and -Xmx (ex: "512M"; "4G")
+	heapReg = regexp.MustCompile(`^[0-9]+[kKmMgG]$`)
+
+	ErrInvalidHeap   = fmt.Errorf("invalid heap size")
+	ErrHeapRange     = fmt.Errorf("min heap is bigger than max heap")
+	ErrUnknownPreset = fmt.Errorf("unknown jvm preset")
+)
+
+// the jvm refuses smaller heaps
+const minHeapBytes = 1 << 20
+
+// DefaultMaxHeap is used when a JVMConfig does not specify a max heap
+// (it is the value that used to be hardcoded)
+const DefaultMaxHeap = "4G"
+
+// Presets are named sets of jvm flags that can be referenced by JVMConfig.Presets
+var Presets = map[string][]string{
+	// https://docs.papermc.io/paper/aikars-flags
+	"aikar": {
+		"-XX:+UseG1GC",
+		"-XX:+ParallelRefProcEnabled",
+		"-XX:MaxGCPauseMillis=200",
+		"-XX:+UnlockExperimentalVMOptions",
+		"-XX:+DisableExplicitGC",
+		"-XX:+AlwaysPreTouch",
+		"-XX:G1NewSizePercent=30",
+		"-XX:G1MaxNewSizePercent=40",
+		"-XX:G1HeapRegionSize=8M",
+		"-XX:G1ReservePercent=20",
+		"-XX:G1HeapWastePercent=5",
+		"-XX:G1MixedGCCountTarget=4",
+		"-XX:InitiatingHeapOccupancyPercent=15",
+		"-XX:G1MixedGCLiveThresholdPercent=90",
+		"-XX:G1RSetUpdatingPauseIntervalMillis=100",
+		"-XX:SurvivorRatio=32",
+		"-XX:+PerfDisableSharedMem",
+		"-XX:MaxTenuringThreshold=1",
+		"-Dusing.aikars.flags=https://mcflags.emc.gs",
+		"-Daikars.new.flags=true",
+	},
+	"g1gc": {
+		"-XX:+UseG1GC",
+		"-XX:MaxGCPauseMillis=200",
+	},
+	"zgc": {
+		"-XX:+UseZGC",
+	},
+}
+
+type JVMConfig struct {
+	MinHeap    string   `json:"min-heap"`
+	MaxHeap    string   `json:"max-heap"`
+	Presets    []string `json:"presets"`
+	Flags      []string `json:"flags"`
+	ServerArgs []string `json:"server-args"`
+}
+
+func NewJVMConfig() *JVMConfig {
+	return &JVMConfig{
+		MaxHeap:    DefaultMaxHeap,
+		Presets:    []string{},
+		Flags:      []string{},
+		ServerArgs: []string{},
+	}
+}
+
+func (c *JVMConfig) Validate() error {
+	var max = c.MaxHeap
+	if max == "" {
+		max = DefaultMaxHeap
+	}
+	maxBytes, err := validHeap(max)
+	if err != nil {
+		return err
+	}
+	if c.MinHeap != "" {
+		minBytes, err := validHeap(c.MinHeap)
+		if err != nil {
+			return err
+		}
+		if minBytes > maxBytes {
+			return fmt.Errorf("%w: %v > %v", ErrHeapRange, c.MinHeap, max)
+		}
+	}
+	for _, p := range c.Presets {
+		if _, ok := Presets[p]; !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownPreset, p)
+		}
+	}
+	return nil
+}
+
+// validHeap returns the number of bytes of size, which must have a unit and be at least 1M
+func validHeap(size string) (int64, error) {
+	if !heapReg.MatchString(size) {
+		return 0, fmt.Errorf("%w: %q must be a number followed by K, M or G", ErrInvalidHeap, size)
+	}
+	n, err := heapBytes(size)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidHeap, size)
+	}
+	if n < minHeapBytes {
+		return 0, fmt.Errorf("%w: %q is smaller than 1M", ErrInvalidHeap, size)
+	}
+	return n, nil
+}
+
+// heapBytes returns the number of bytes of a heap size matching heapReg
+func heapBytes(size string) (int64, error) {
+	var shift uint
+	switch strings.ToLower(size[len(size)-1:]) {
+	case "k":
+		shift = 10
+	case "m":
+		shift = 20
+	case "g":
+		shift = 30
+	}
+	if shift != 0 {
+		size = size[:len(size)-1]
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || n > 1<<(63-shift)-1 {
+		return 0, ErrInvalidHeap
+	}
+	return n << shift, nil
+}
+
+// Args returns the arguments to pass to the java executable in order to run jarPath
+//
+// c can be nil, in which case only the default max heap is set
+func (c *JVMConfig) Args(jarPath string) []string {
+	if c == nil {
+		c = &JVMConfig{}
+	}
+	var args = []string{}
+	if c.MinHeap != "" {
+		args = append(args, "-Xms"+c.MinHeap)
+	}
+	if c.MaxHeap != "" {
+		args = append(args, "-Xmx"+c.MaxHeap)
+	} else {
+		args = append(args, "-Xmx"+DefaultMaxHeap)
+	}
+	for _, p := range c.Presets {
+		args = append(args, Presets[p]...)
+	}
+	args = append(args, c.Flags...)
+	args = append(args, "-jar", jarPath, "nogui")
+	return append(args, c.ServerArgs...)
+}
@@ -0,0 +1,33 @@
+package servers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJVMConfigValidate(t *testing.T) {
+	var tests = []struct {
+		min, max string
+		err      error
+	}{
+		{"", "", nil},
+		{"1G", "4G", nil},
+		{"512M", "", nil},
+		{"1024k", "1M", nil},
+		{"", "0", ErrInvalidHeap},
+		{"", "0M", ErrInvalidHeap},
+		{"", "2048", ErrInvalidHeap},
+		{"1", "4G", ErrInvalidHeap},
+		{"1023K", "4G", ErrInvalidHeap},
+		{"", "4T", ErrInvalidHeap},
+		{"8G", "", ErrHeapRange},
+		{"2G", "1024M", ErrHeapRange},
+	}
+	for _, test := range tests {
+		var cfg = &JVMConfig{MinHeap: test.min, MaxHeap: test.max}
+		err := cfg.Validate()
+		if !errors.Is(err, test.err) || (test.err == nil && err != nil) {
+			t.Errorf("min %q max %q: got %v, want %v", test.min, test.max, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package servers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"mineOS/globals"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidLimits = fmt.Errorf("invalid resource limits")
+)
+
+const (
+	// period used for cpu.max, in microseconds
+	cpuPeriod = 100000
+
+	joinPollInterval = 50 * time.Millisecond
+
+	// leaf cgroup mineOS moves itself into when its own cgroup must enable controllers, see enableControllers
+	managerCgroup = "mineos-manager"
+)
+
+// Limits of the server process tree, 0 (or false) means no limit
+//
+// CPUs, MemoryMax and PidsMax are enforced through a cgroup v2 (when cgroups are writable),
+// NoFile and DisableCoreDumps through rlimits
+type Limits struct {
+	CPUs      float64 `json:"cpus"`       // 1.5 is one and a half core
+	MemoryMax int64   `json:"memory-max"` // bytes
+	PidsMax   int64   `json:"pids-max"`
+
+	NoFile           int64 `json:"nofile"` // max open files per process
+	DisableCoreDumps bool  `json:"disable-core-dumps"`
+}
+
+func (l *Limits) Validate() error {
+	if l.CPUs < 0 || l.MemoryMax < 0 || l.PidsMax < 0 || l.NoFile < 0 {
+		return fmt.Errorf("%w: negative values", ErrInvalidLimits)
+	}
+	if l.CPUs != 0 && l.CPUs*cpuPeriod < 1000 {
+		return fmt.Errorf("%w: cpus must be at least 0.01", ErrInvalidLimits)
+	}
+	return nil
+}
+
+// controllers returns the cgroup controllers needed to enforce l
+func (l *Limits) controllers() []string {
+	var c = []string{}
+	if l == nil {
+		return c
+	}
+	if l.CPUs != 0 {
+		c = append(c, "+cpu")
+	}
+	if l.MemoryMax != 0 {
+		c = append(c, "+memory")
+	}
+	if l.PidsMax != 0 {
+		c = append(c, "+pids")
+	}
+	return c
+}
+
+// CgroupUsage is read from the cgroup of the server
+type CgroupUsage struct {
+	MemoryCurrent int64 `json:"memory-current"` // bytes
+	PidsCurrent   int64 `json:"pids-current"`
+	CPUUsageUsec  int64 `json:"cpu-usage-usec"`
+	ThrottledUsec int64 `json:"cpu-throttled-usec"`
+	OOMKills      int64 `json:"oom-kills"`
+}
+
+// Cgroup returns the cgroup the server runs in ("" if none)
+func (s *Server) Cgroup() string {
+	s.cgroupmu.Lock()
+	defer s.cgroupmu.Unlock()
+	return s.cgroup
+}
+
+func (s *Server) setCgroup(path string) {
+	s.cgroupmu.Lock()
+	s.cgroup = path
+	s.cgroupmu.Unlock()
+}
+
+// CgroupError returns why the server last started without its cgroup ("" if it did not)
+func (s *Server) CgroupError() string {
+	s.cgroupmu.Lock()
+	defer s.cgroupmu.Unlock()
+	return s.cgroupErr
+}
+
+// limitsUnavailable reports that the server runs without cgroup limits
+func (s *Server) limitsUnavailable(msg string) {
+	fmt.Printf("server %v runs without cgroup limits: %v\n", s.JarPath, msg)
+	s.cgroupmu.Lock()
+	s.cgroupErr = msg
+	s.cgroupmu.Unlock()
+	s.emit("limits-unavailable", map[string]interface{}{"error": msg})
+}
+
+// cgroupPath is the cgroup used by the server, named after its folder
+func (s *Server) cgroupPath() string {
+	return filepath.Join(globals.CgroupRoot.Get(), filepath.Base(filepath.Dir(s.JarPath)))
+}
+
+// setupCgroup creates the cgroup of the server and writes its limits
+//
+// if cgroups v2 are unavailable or not writable, the server runs without it:
+// a limits-unavailable event is sent and "" is returned
+func (s *Server) setupCgroup() string {
+	s.cgroupmu.Lock()
+	s.cgroupErr = ""
+	s.cgroupmu.Unlock()
+	var controllers = s.Limits.controllers()
+	if len(controllers) == 0 {
+		return ""
+	}
+	var path = s.cgroupPath()
+	err := createCgroup(path, controllers)
+	if err == nil {
+		err = writeCgroupLimits(path, s.Limits)
+	}
+	if err != nil {
+		s.limitsUnavailable(err.Error())
+		return ""
+	}
+	return path
+}
+
+// checkCgroupJoin waits for the supervisor to join the cgroup of the server (it then writes server.pid)
+//
+// if it failed, the server runs without cgroup limits: the cgroup is removed and a
+// limits-unavailable event is sent
+func (s *Server) checkCgroupJoin(exited chan struct{}) {
+	var path = s.Cgroup()
+	if path == "" {
+		return
+	}
+	for {
+		if _, err := os.Stat(s.supervisorFile("server.pid")); err == nil {
+			break
+		}
+		select {
+		case <-exited:
+			return
+		case <-time.After(joinPollInterval):
+		}
+	}
+	data, err := os.ReadFile(s.supervisorFile("cgroup-error"))
+	if err != nil {
+		return // joined
+	}
+	s.removeCgroup()
+	s.limitsUnavailable(fmt.Sprintf("failed to join cgroup %v: %v", path, strings.TrimSpace(string(data))))
+}
+
+// createCgroup creates path and enables controllers (ex: "+memory") in the subtree_control of all its parents
+func createCgroup(path string, controllers []string) error {
+	var mount = cgroupMount()
+	if mount == "" {
+		return fmt.Errorf("cgroup v2 is not mounted")
+	}
+	rel, err := filepath.Rel(mount, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("%v is not inside the cgroup v2 mount %v", path, mount)
+	}
+	var parent = mount
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		err = enableControllers(parent, controllers)
+		if err != nil {
+			return err
+		}
+		parent = filepath.Join(parent, name)
+		err = os.Mkdir(parent, 0755)
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// enableControllers writes controllers to the subtree_control of dir
+//
+// a cgroup holding processes cannot enable controllers for its children (except the root one):
+// if mineOS itself is in dir, it is moved to the managerCgroup leaf first
+func enableControllers(dir string, controllers []string) error {
+	var file = filepath.Join(dir, "cgroup.subtree_control")
+	var value = []byte(strings.Join(controllers, " "))
+	err := os.WriteFile(file, value, 0644)
+	if !errors.Is(err, syscall.EBUSY) {
+		return err
+	}
+	if processCgroup(os.Getpid()) != dir {
+		return fmt.Errorf("cgroup %v holds processes, controllers cannot be enabled for its children: %w", dir, err)
+	}
+	var leaf = filepath.Join(dir, managerCgroup)
+	err = os.Mkdir(leaf, 0755)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to move mineOS to %v: %w", leaf, err)
+	}
+	fmt.Printf("moved mineOS to cgroup %v so that %v can enable controllers\n", leaf, dir)
+	err = os.WriteFile(file, value, 0644)
+	if errors.Is(err, syscall.EBUSY) {
+		return fmt.Errorf("cgroup %v holds other processes, controllers cannot be enabled for its children: %w", dir, err)
+	}
+	return err
+}
+
+// processCgroup returns the path of the cgroup v2 of pid ("" if unknown)
+func processCgroup(pid int) string {
+	var mount = cgroupMount()
+	if mount == "" {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return filepath.Join(mount, line[3:])
+		}
+	}
+	return ""
+}
+
+// cgroupMount returns the mount point of the cgroup v2 hierarchy ("" if there is none)
+func cgroupMount() string {
+	for _, dir := range []string{"/sys/fs/cgroup", "/sys/fs/cgroup/unified"} {
+		if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
+func writeCgroupLimits(path string, l *Limits) error {
+	var cpu = "max"
+	if l.CPUs != 0 {
+		cpu = strconv.Itoa(int(l.CPUs * cpuPeriod))
+	}
+	var files = map[string]string{
+		"cpu.max":    fmt.Sprintf("%v %v", cpu, cpuPeriod),
+		"memory.max": limitValue(l.MemoryMax),
+		"pids.max":   limitValue(l.PidsMax),
+	}
+	for name, value := range files {
+		err := os.WriteFile(filepath.Join(path, name), []byte(value), 0644)
+		// files of controllers that are not enabled do not exist, which is fine when there is no limit
+		if err != nil && !(os.IsNotExist(err) && strings.HasPrefix(value, "max")) {
+			return err
+		}
+	}
+	return nil
+}
+
+func limitValue(v int64) string {
+	if v == 0 {
+		return "max"
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// removeCgroup removes the cgroup of the server once it exited (it fails if processes remain)
+func (s *Server) removeCgroup() {
+	var path = s.Cgroup()
+	if path == "" {
+		return
+	}
+	s.setCgroup("")
+	err := os.Remove(path)
+	if err != nil {
+		fmt.Printf("failed to remove cgroup %v: %v\n", path, err)
+	}
+}
+
+// findCgroup returns the cgroup of the server if pid (the supervisor) runs in it
+func (s *Server) findCgroup(pid int) string {
+	if processCgroup(pid) == s.cgroupPath() {
+		return s.cgroupPath()
+	}
+	return ""
+}
+
+// GetCgroupUsage returns the current usage of the cgroup of the server (nil if it has none)
+func (s *Server) GetCgroupUsage() *CgroupUsage {
+	var path = s.Cgroup()
+	if path == "" {
+		return nil
+	}
+	var u = &CgroupUsage{}
+	u.MemoryCurrent, _ = readCgroupInt(filepath.Join(path, "memory.current"))
+	u.PidsCurrent, _ = readCgroupInt(filepath.Join(path, "pids.current"))
+	var cpu = readCgroupKeys(filepath.Join(path, "cpu.stat"))
+	u.CPUUsageUsec, u.ThrottledUsec = cpu["usage_usec"], cpu["throttled_usec"]
+	u.OOMKills = readCgroupKeys(filepath.Join(path, "memory.events"))["oom_kill"]
+	return u
+}
+
+func readCgroupInt(file string) (int64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readCgroupKeys reads flat keyed files (ex: cpu.stat)
+func readCgroupKeys(file string) map[string]int64 {
+	var m = map[string]int64{}
+	f, err := os.Open(file)
+	if err != nil {
+		return m
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, val, ok := strings.Cut(sc.Text(), " ")
+		if !ok {
+			continue
+		}
+		m[key], _ = strconv.ParseInt(val, 10, 64)
+	}
+	return m
+}
+
+// supervisorEnv returns the environment used by the supervisor to apply limits
+func (s *Server) supervisorEnv(cgroup string) []string {
+	var env = os.Environ()
+	if cgroup != "" {
+		env = append(env, "MINEOS_CGROUP="+cgroup)
+	}
+	if s.Limits != nil && s.Limits.NoFile != 0 {
+		env = append(env, fmt.Sprintf("MINEOS_NOFILE=%v", s.Limits.NoFile))
+	}
+	if s.Limits != nil && s.Limits.DisableCoreDumps {
+		env = append(env, "MINEOS_CORE=0")
+	}
+	return env
+}
@@ -0,0 +1,86 @@
+package servers
+
+import (
+	"regexp"
+	"strings"
+)
+
+type LogLevel string
+
+type Stream string
+
+const (
+	Stdout Stream = "stdout"
+	Stderr Stream = "stderr"
+)
+
+const (
+	Trace LogLevel = "TRACE"
+	Debug LogLevel = "DEBUG"
+	Info  LogLevel = "INFO"
+	Warn  LogLevel = "WARN"
+	Error LogLevel = "ERROR"
+	Fatal LogLevel = "FATAL"
+)
+
+var (
+	levelRanks = map[LogLevel]int{Trace: 0, Debug: 1, Info: 2, Warn: 3, Error: 4, Fatal: 5}
+
+	//[20:41:32] [Server thread/INFO]: Stopping server
+	//[20:41:32] [Server thread/INFO] [minecraft/DedicatedServer]: Stopping server
+	threadLogReg = regexp.MustCompile(`^\[([0-9:.]+)\] \[([^\]]+)/([A-Z]+)\](?: \[([^\]]+)\])?: ?(.*)$`)
+
+	//[20:41:32 INFO]: Stopping server
+	levelLogReg = regexp.MustCompile(`^\[([0-9:.]+) ([A-Z]+)\](?: \[([^\]]+)\])?: ?(.*)$`)
+)
+
+func ToLogLevel(str string) (LogLevel, bool) {
+	var l = LogLevel(strings.ToUpper(str))
+	_, ok := levelRanks[l]
+	return l, ok
+}
+
+// AtLeast returns true if l is as or more severe than min (unknown levels count as INFO)
+func (l LogLevel) AtLeast(min LogLevel) bool {
+	a, ok := levelRanks[l]
+	if !ok {
+		a = levelRanks[Info]
+	}
+	b, ok := levelRanks[min]
+	if !ok {
+		b = levelRanks[Info]
+	}
+	return a >= b
+}
+
+type LogRecord struct {
+	Time    string   `json:"time"`
+	Thread  string   `json:"thread"`
+	Level   LogLevel `json:"level"`
+	Logger  string   `json:"logger"`
+	Message string   `json:"message"`
+	Raw     string   `json:"raw"`
+	Stream  Stream   `json:"stream"`
+	// false for lines that are not a log entry (ex: stack traces)
+	Parsed bool `json:"parsed"`
+}
+
+// ParseLog parses a line of the minecraft console
+//
+// lines that do not match any known format are returned with only Raw and Message set
+func ParseLog(line string) *LogRecord {
+	var rec = &LogRecord{Raw: line}
+	var trimmed = strings.TrimRight(line, "\r\n")
+	if m := threadLogReg.FindStringSubmatch(trimmed); m != nil {
+		rec.Time, rec.Thread, rec.Level, rec.Logger, rec.Message = m[1], m[2], LogLevel(m[3]), m[4], m[5]
+		rec.Parsed = true
+		return rec
+	}
+	if m := levelLogReg.FindStringSubmatch(trimmed); m != nil {
+		rec.Time, rec.Level, rec.Logger, rec.Message = m[1], LogLevel(m[2]), m[3], m[4]
+		rec.Parsed = true
+		return rec
+	}
+	rec.Message = trimmed
+	return rec
+}
@@ -0,0 +1,176 @@
+package servers
+
+import (
+	"bufio"
+	"fmt"
+	"mineOS/globals"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clock ticks per second used by /proc/[pid]/stat (USER_HZ, 100 on all common platforms)
+const clockTicks = 100
+
+// Metrics of the server process and its descendants, sampled from /proc
+type Metrics struct {
+	Time       time.Time `json:"time"`
+	CPUPercent float64   `json:"cpu-percent"` // 100% is one core fully used
+	RSS        int64     `json:"rss"`         // bytes
+	Threads    int       `json:"threads"`
+	FDs        int       `json:"fds"`
+	ReadBytes  int64     `json:"read-bytes"`
+	WriteBytes int64     `json:"write-bytes"`
+	Processes  int       `json:"processes"`
+
+	cpuTicks int64
+}
+
+// GetMetrics returns the latest metrics sample (nil if none was taken since last start)
+func (s *Server) GetMetrics() *Metrics {
+	s.metricsmu.RLock()
+	defer s.metricsmu.RUnlock()
+	return s.metrics
+}
+
+// sampleMetrics samples the process every metrics-interval seconds until it exits
+func (s *Server) sampleMetrics(pid int, exited chan struct{}) {
+	var interval = time.Duration(globals.MetricsInterval.Get()) * time.Second
+	if interval <= 0 {
+		return
+	}
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+	var prev *Metrics
+	for {
+		select {
+		case <-exited:
+			s.metricsmu.Lock()
+			s.metrics = nil
+			s.metricsmu.Unlock()
+			return
+		case <-ticker.C:
+		}
+		m, err := sampleTree(pid)
+		if err != nil {
+			continue // process is exiting or /proc is unavailable
+		}
+		if prev != nil {
+			var elapsed = m.Time.Sub(prev.Time).Seconds()
+			if elapsed > 0 {
+				m.CPUPercent = float64(m.cpuTicks-prev.cpuTicks) / clockTicks / elapsed * 100
+			}
+		}
+		prev = m
+		s.metricsmu.Lock()
+		s.metrics = m
+		s.metricsmu.Unlock()
+		s.emit("metrics", map[string]interface{}{"metrics": m})
+	}
+}
+
+// sampleTree sums the metrics of pid and all its descendants
+func sampleTree(root int) (*Metrics, error) {
+	var m = &Metrics{Time: time.Now()}
+	var pids = descendants(root)
+	for _, pid := range pids {
+		var dir = filepath.Join("/proc", strconv.Itoa(pid))
+		ticks, threads, rss, err := readStat(dir)
+		if err != nil {
+			if pid == root {
+				return nil, err
+			}
+			continue
+		}
+		m.cpuTicks += ticks
+		m.Threads += threads
+		m.RSS += rss
+		m.Processes++
+		if fds, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+			m.FDs += len(fds)
+		}
+		if r, w, err := readIO(dir); err == nil {
+			m.ReadBytes += r
+			m.WriteBytes += w
+		}
+	}
+	return m, nil
+}
+
+// descendants returns root followed by all its descendants
+func descendants(root int) []int {
+	var children = map[int][]int{}
+	entries, _ := os.ReadDir("/proc")
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		fields, err := statFields(filepath.Join("/proc", e.Name()))
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		children[ppid] = append(children[ppid], pid)
+	}
+	var pids = []int{root}
+	for i := 0; i < len(pids); i++ {
+		pids = append(pids, children[pids[i]]...)
+	}
+	return pids
+}
+
+// statFields returns the fields of /proc/[pid]/stat following the command name
+// (fields[0] is the state, so fields[i] is field i+3 of proc(5))
+func statFields(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	var str = string(data)
+	var i = strings.LastIndexByte(str, ')')
+	if i == -1 {
+		return nil, fmt.Errorf("invalid stat file in %v", dir)
+	}
+	var fields = strings.Fields(str[i+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("invalid stat file in %v", dir)
+	}
+	return fields, nil
+}
+
+func readStat(dir string) (ticks int64, threads int, rss int64, err error) {
+	fields, err := statFields(dir)
+	if err != nil {
+		return
+	}
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	threads, _ = strconv.Atoi(fields[17])
+	pages, _ := strconv.ParseInt(fields[21], 10, 64)
+	return utime + stime, threads, pages * int64(os.Getpagesize()), nil
+}
+
+func readIO(dir string) (read int64, write int64, err error) {
+	f, err := os.Open(filepath.Join(dir, "io"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, val, ok := strings.Cut(sc.Text(), ": ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "read_bytes":
+			read, _ = strconv.ParseInt(val, 10, 64)
+		case "write_bytes":
+			write, _ = strconv.ParseInt(val, 10, 64)
+		}
+	}
+	return read, write, sc.Err()
+}
@@ -0,0 +1,144 @@
+package servers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	//[16:20:03] [User Authenticator #1/INFO]: UUID of player Steve is 8667ba71-b85a-4004-af54-457a9734eed7
+	uuidReg = regexp.MustCompile(`^UUID of player (\S+) is ([0-9a-fA-F-]+)$`)
+	//[16:20:04] [Server thread/INFO]: Steve joined the game
+	joinReg = regexp.MustCompile(`^(\S+) joined the game$`)
+	//[16:25:41] [Server thread/INFO]: Steve left the game
+	leaveReg = regexp.MustCompile(`^(\S+) left the game$`)
+	//[16:30:00] [Server thread/INFO]: There are 2 of a max of 20 players online: Steve, Alex
+	listReg = regexp.MustCompile(`^There are \d+ of a max of \d+ players online:(.*)$`)
+)
+
+type Player struct {
+	Name     string    `json:"name"`
+	UUID     string    `json:"uuid"`
+	JoinedAt time.Time `json:"joined-at"`
+}
+
+// GetPlayers returns the online players sorted by join time
+func (s *Server) GetPlayers() []*Player {
+	s.playersmu.RLock()
+	defer s.playersmu.RUnlock()
+	var players = make([]*Player, 0, len(s.players))
+	for _, p := range s.players {
+		players = append(players, p)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].JoinedAt.Before(players[j].JoinedAt)
+	})
+	return players
+}
+
+func (s *Server) PlayerCount() int {
+	s.playersmu.RLock()
+	defer s.playersmu.RUnlock()
+	return len(s.players)
+}
+
+// trackPlayers updates the online players from a console line
+func (s *Server) trackPlayers(rec *LogRecord) {
+	if !rec.Parsed {
+		return
+	}
+	if s.setPlayers(rec.Message) {
+		return
+	}
+	if m := uuidReg.FindStringSubmatch(rec.Message); m != nil {
+		s.playersmu.Lock()
+		s.uuids[m[1]] = m[2]
+		s.playersmu.Unlock()
+		return
+	}
+	if m := joinReg.FindStringSubmatch(rec.Message); m != nil {
+		s.playersmu.Lock()
+		var p = &Player{Name: m[1], UUID: s.uuids[m[1]], JoinedAt: time.Now()}
+		s.players[p.Name] = p
+		delete(s.uuids, p.Name)
+		s.playersmu.Unlock()
+		s.emit("player-join", map[string]interface{}{"name": p.Name, "uuid": p.UUID})
+		return
+	}
+	if m := leaveReg.FindStringSubmatch(rec.Message); m != nil {
+		s.playersmu.Lock()
+		p, ok := s.players[m[1]]
+		delete(s.players, m[1])
+		s.playersmu.Unlock()
+		if ok {
+			s.emit("player-leave", map[string]interface{}{"name": p.Name, "uuid": p.UUID})
+		}
+	}
+}
+
+// refreshPlayers asks the server for its online players (used once a reattached server was replayed)
+func (s *Server) refreshPlayers() {
+	out, viaRcon, err := s.Exec("list")
+	if err != nil {
+		fmt.Printf("failed to list players of server %v: %v\n", s.JarPath, err)
+		return
+	}
+	if viaRcon { // otherwise the answer is read from the console by trackPlayers
+		s.setPlayers(out)
+	}
+}
+
+// setPlayers replaces the online players with the ones of the output of the list command
+//
+// returns false if msg is not such an output
+func (s *Server) setPlayers(msg string) bool {
+	var m = listReg.FindStringSubmatch(strings.TrimSpace(msg))
+	if m == nil {
+		return false
+	}
+	var online = map[string]bool{}
+	for _, name := range strings.Split(m[1], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			online[name] = true
+		}
+	}
+	var joined, left []*Player
+	s.playersmu.Lock()
+	for name, p := range s.players {
+		if !online[name] {
+			delete(s.players, name)
+			left = append(left, p)
+		}
+	}
+	for name := range online {
+		if _, ok := s.players[name]; !ok {
+			var p = &Player{Name: name, UUID: s.uuids[name], JoinedAt: time.Now()}
+			s.players[name] = p
+			delete(s.uuids, name)
+			joined = append(joined, p)
+		}
+	}
+	s.playersmu.Unlock()
+	for _, p := range left {
+		s.emit("player-leave", map[string]interface{}{"name": p.Name, "uuid": p.UUID})
+	}
+	for _, p := range joined {
+		s.emit("player-join", map[string]interface{}{"name": p.Name, "uuid": p.UUID})
+	}
+	return true
+}
+
+// all players are considered offline once the process exits
+func (s *Server) clearPlayers() {
+	s.playersmu.Lock()
+	var players = s.players
+	s.players = map[string]*Player{}
+	s.uuids = map[string]string{}
+	s.playersmu.Unlock()
+	for _, p := range players {
+		s.emit("player-leave", map[string]interface{}{"name": p.Name, "uuid": p.UUID})
+	}
+}
@@ -0,0 +1,113 @@
+package servers
+
+import (
+	"errors"
+	"fmt"
+	"mineOS/rcon"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	//[20:41:05] [RCON Listener #1/INFO]: RCON running on 0.0.0.0:25575
+	rconReadyReg = regexp.MustCompile(`^RCON running on `)
+)
+
+const (
+	// default time given to a command sent through rcon to return
+	rconTimeout = 10 * time.Second
+	// minimum time between two attempts to connect again once rcon was lost
+	rconRetryInterval = 30 * time.Second
+)
+
+// connectRcon is called once the server reported that rcon is running
+func (s *Server) connectRcon() {
+	if s.RconPort == 0 || s.RconPassword == "" {
+		return
+	}
+	c, err := rcon.Dial("127.0.0.1:"+strconv.Itoa(s.RconPort), s.RconPassword, rconTimeout)
+	if err != nil {
+		fmt.Printf("failed to connect to rcon of server %v: %v\n", s.JarPath, err)
+		return
+	}
+	s.rconmu.Lock()
+	if s.rcon != nil {
+		s.rcon.Close()
+	}
+	s.rcon = c
+	s.rconLost = false
+	s.rconmu.Unlock()
+}
+
+// reconnectRcon connects rcon again if it was lost, at most once every rconRetryInterval
+func (s *Server) reconnectRcon() {
+	s.rconmu.Lock()
+	var retry = s.rcon == nil && s.rconLost && time.Since(s.rconRetry) >= rconRetryInterval
+	if retry {
+		s.rconRetry = time.Now()
+	}
+	s.rconmu.Unlock()
+	if retry {
+		s.connectRcon()
+	}
+}
+
+func (s *Server) closeRcon() {
+	s.rconmu.Lock()
+	defer s.rconmu.Unlock()
+	s.rconLost = false
+	if s.rcon != nil {
+		s.rcon.Close()
+		s.rcon = nil
+	}
+}
+
+// HasRcon returns true if commands are currently sent through rcon
+func (s *Server) HasRcon() bool {
+	s.rconmu.Lock()
+	defer s.rconmu.Unlock()
+	return s.rcon != nil
+}
+
+// Exec sends cmd through rcon and returns its output, see ExecTimeout
+func (s *Server) Exec(cmd string) (out string, viaRcon bool, err error) {
+	return s.ExecTimeout(cmd, rconTimeout)
+}
+
+// ExecTimeout sends cmd through rcon and returns its output if it returns within timeout.
+//
+// if rcon is unavailable or the command could not be sent, cmd is sent through stdin instead:
+// viaRcon is then false and the output can only be found in the console; if the command was sent
+// but its output could not be read, it is not sent again (it may have run) and an error is returned.
+// A lost rcon connection is opened again on a later command.
+func (s *Server) ExecTimeout(cmd string, timeout time.Duration) (out string, viaRcon bool, err error) {
+	if s.State != Starting && s.State != Running {
+		return "", false, ErrNotStarted
+	}
+	cmd = strings.TrimRight(cmd, "\r\n")
+	s.reconnectRcon()
+	s.rconmu.Lock()
+	if s.rcon != nil {
+		out, err = s.rcon.CommandTimeout(cmd, timeout)
+		if err == nil {
+			s.rconmu.Unlock()
+			return out, true, nil
+		}
+		if !errors.Is(err, rcon.ErrTooLong) {
+			s.rcon.Close()
+			s.rcon = nil
+			s.rconLost = true
+			s.rconRetry = time.Now()
+		}
+		if errors.Is(err, rcon.ErrNoResponse) {
+			s.rconmu.Unlock()
+			fmt.Printf("rcon command failed on server %v after being sent: %v\n", s.JarPath, err)
+			return "", true, err
+		}
+		fmt.Printf("rcon command failed on server %v, falling back to stdin: %v\n", s.JarPath, err)
+	}
+	s.rconmu.Unlock()
+	return "", false, s.SendCommand(cmd)
+}
@@ -0,0 +1,91 @@
+package servers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"mineOS/rcon"
+)
+
+// startRcon starts a fake rcon server accepting any password and never answering commands
+func startRcon(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					var size, id, typ int32
+					if binary.Read(conn, binary.LittleEndian, &size) != nil {
+						return
+					}
+					binary.Read(conn, binary.LittleEndian, &id)
+					binary.Read(conn, binary.LittleEndian, &typ)
+					io.CopyN(io.Discard, conn, int64(size-8))
+					if typ == 3 { // auth
+						var buf = &bytes.Buffer{}
+						binary.Write(buf, binary.LittleEndian, []int32{10, id, 2})
+						buf.Write([]byte{0, 0})
+						conn.Write(buf.Bytes())
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func runningServer(rconPort int) *Server {
+	var s = NewServer("server.jar")
+	s.State = Running
+	s.RconPort = rconPort
+	s.RconPassword = "secret"
+	return s
+}
+
+// a command sent through rcon without answer may have run: it must not be sent again through stdin
+func TestExecNoResponseNotResent(t *testing.T) {
+	var s = runningServer(startRcon(t))
+	s.connectRcon()
+	if !s.HasRcon() {
+		t.Fatal("rcon not connected")
+	}
+	_, viaRcon, err := s.ExecTimeout("give Steve diamond", 100*time.Millisecond)
+	if !errors.Is(err, rcon.ErrNoResponse) || !viaRcon {
+		t.Fatalf("expected ErrNoResponse through rcon, got %v, %v", viaRcon, err)
+	}
+	if len(s.inputs) != 0 {
+		t.Fatal("command was sent again through stdin")
+	}
+	// the next command reconnects once rconRetryInterval passed
+	s.rconRetry = time.Now().Add(-rconRetryInterval)
+	s.ExecTimeout("list", 100*time.Millisecond)
+	if len(s.inputs) != 0 {
+		t.Fatal("command was sent through stdin instead of reconnecting")
+	}
+}
+
+func TestExecFallback(t *testing.T) {
+	var s = runningServer(0) // rcon never connected
+	out, viaRcon, err := s.Exec("list")
+	if err != nil || viaRcon || out != "" {
+		t.Fatalf("got %q, %v, %v", out, viaRcon, err)
+	}
+	if cmd := <-s.inputs; cmd != "list\n" {
+		t.Fatalf("got %q on stdin", cmd)
+	}
+}
@@ -1,16 +1,17 @@
 package servers
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"mineOS/archive"
 	"mineOS/downloads"
-	"mineOS/zip"
-	"os"
+	"mineOS/rcon"
+	"mineOS/status"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Amqp-prtcl/snowflakes"
@@ -23,8 +24,10 @@ const (
 	Running  ServerState = "RUNNING"
 	Stopping ServerState = "STOPPING"
 	Closed   ServerState = "CLOSED"
+	Crashed  ServerState = "CRASHED" // process exited with an error
 
-	Zipping ServerState = "ZIPPING"
+	Zipping     ServerState = "ZIPPING"
+	Maintenance ServerState = "MAINTENANCE" // files of the server are being changed (ex: worlds)
 )
 
 var (
@@ -38,20 +41,78 @@ var (
 	ErrNotStarted = fmt.Errorf("Server not started")
 )
 
+// IsClosed returns true if no process is running (the server can be started)
+func (st ServerState) IsClosed() bool {
+	return st == Closed || st == Crashed
+}
+
 type Server struct {
 	JarPath string
 	State   ServerState
+	statemu sync.Mutex // held to leave the closed states (see Start and Maintain)
+	JVM     *JVMConfig
+	Java    string // java executable ("" uses java from PATH)
+
+	StopTimeouts *StopTimeouts // see SetStopTimeouts once the server is in use
+
+	// address used to ping the server, if Port is 0 the server is
+	// considered running without being pinged
+	Host string
+	Port int
+
+	// rcon is only used if both are set
+	RconPort     int
+	RconPassword string
+
+	// error returned by the last process (nil if it exited normally)
+	ExitErr error
 
 	OnStateChange func(*Server)
-	OnLog         func(*Server, string)
+	OnLog         func(*Server, *LogRecord)
+	OnEvent       func(s *Server, event string, data map[string]interface{})
+
+	cmd           *exec.Cmd // nil if the server was reattached
+	supervisorPid int
+	input         io.WriteCloser
+
+	replaying bool
+	replaymu  sync.Mutex
+
+	Limits    *Limits
+	cgroup    string // "" if the server does not run in a cgroup
+	cgroupErr string // see CgroupError
+	cgroupmu  sync.Mutex
+
+	res       chan error
+	logs      chan logLine
+	inputs    chan string
+	exited    chan struct{} // closed when the process exits
+	confirmed chan bool     // result of confirmRunning
+
+	lastLevel  LogLevel // level given to lines that are not log entries
+	confirming bool     // confirmRunning is running
+
+	players   map[string]*Player // by name
+	uuids     map[string]string  // uuids of players that are joining
+	playersmu sync.RWMutex
+
+	rcon      *rcon.Client
+	rconLost  bool      // the connection failed while the server was running
+	rconRetry time.Time // last attempt to connect again
+	rconmu    sync.Mutex
+
+	metrics   *Metrics
+	metricsmu sync.RWMutex
 
-	cmd    *exec.Cmd
-	input  io.WriteCloser
-	output io.ReadCloser
+	status   *status.Status // see GetStatus
+	statusmu sync.RWMutex
 
-	res    chan error
-	logs   chan string
-	inputs chan string
+	stopmu     sync.Mutex
+	escalation *time.Timer
+	signaled   bool
+
+	watches []*logWatch
+	watchmu sync.Mutex
 }
 
 func NewServer(jarPath string) *Server {
@@ -59,6 +120,8 @@ func NewServer(jarPath string) *Server {
 		JarPath: jarPath,
 		State:   Closed,
 		inputs:  make(chan string, 10),
+		players: map[string]*Player{},
+		uuids:   map[string]string{},
 	}
 }
 
@@ -67,42 +130,36 @@ func (s *Server) setState(st ServerState) {
 	s.OnStateChange(s)
 }
 
-func (s *Server) Start() error {
-	if s.State != Closed {
-		return ErrNotClosed
+func (s *Server) emit(event string, data map[string]interface{}) {
+	if s.OnEvent != nil {
+		s.OnEvent(s, event, data)
 	}
-	s.res = make(chan error, 1)
-	s.logs = make(chan string, 10)
-	s.inputs = make(chan string, 10)
-
-	s.cmd = exec.Command("java", "-Xmx4G", "-jar", s.JarPath, "nogui")
-	s.cmd.Dir = filepath.Dir(s.JarPath)
+}
 
-	var err error
-	s.input, err = s.cmd.StdinPipe()
-	if err != nil {
-		return err
+// Start starts the closed server; prepare (if not nil) is called once the server is known to be closed,
+// right before starting it, no other Start or Maintain can run meanwhile
+func (s *Server) Start(prepare func() error) error {
+	s.statemu.Lock()
+	defer s.statemu.Unlock()
+	if !s.State.IsClosed() {
+		return ErrNotClosed
 	}
-	s.output, err = s.cmd.StdoutPipe()
-	if err != nil {
-		return err
+	if prepare != nil {
+		err := prepare()
+		if err != nil {
+			return err
+		}
 	}
-
-	s.cmd.Stderr = os.Stderr
-
-	go s.listenServer()
-	err = s.cmd.Start()
+	s.setCgroup(s.setupCgroup())
+	var java = s.Java
+	if java == "" {
+		java = "java"
+	}
+	err := s.startSupervised(java, s.JVM.Args(s.JarPath))
 	if err != nil {
-		return err
+		s.removeCgroup()
 	}
-
-	s.setState(Starting)
-
-	go s.processHandler()
-	go func() {
-		s.res <- s.cmd.Wait()
-	}()
-	return nil
+	return err
 }
 
 func (s *Server) SendCommand(cmd string) error {
@@ -116,19 +173,45 @@ func (s *Server) SendCommand(cmd string) error {
 	return nil
 }
 
-func (s *Server) Stop() error {
-	return s.SendCommand("stop")
-}
-
 func (s *Server) processHandler() {
 	for {
 		select {
-		case log := <-s.logs:
-			s.OnLog(s, log)
+		case line := <-s.logs:
+			if line.stream == replayDone {
+				s.setReplaying(false)
+				s.endReplay()
+				continue
+			}
+			var log = line.str
+			var rec = ParseLog(log)
+			rec.Stream = line.stream
+			if rec.Parsed {
+				s.lastLevel = rec.Level
+			} else if line.stream == Stderr {
+				rec.Level = Error
+			} else {
+				rec.Level = s.lastLevel
+			}
+			s.OnLog(s, rec)
+			// replayed lines are only used to recover the running state
+			if !s.IsReplaying() {
+				s.trackPlayers(rec)
+				s.notifyWatches(rec)
+				if rec.Parsed && rconReadyReg.MatchString(rec.Message) {
+					go s.connectRcon()
+				}
+			}
+			if line.stream == Stderr {
+				continue
+			}
 			switch s.State {
 			case Starting:
 				if RunningReg.MatchString(log) {
-					s.setState(Running)
+					if s.Port == 0 {
+						s.setState(Running)
+					} else {
+						s.confirm()
+					}
 				}
 			case Running:
 				if stoppingReg.MatchString(log) {
@@ -136,52 +219,90 @@ func (s *Server) processHandler() {
 				}
 			}
 
-		case err := <-s.res:
-			s.setState(Closed)
-			if err != nil {
-				panic(err)
+		case ok := <-s.confirmed:
+			s.confirming = false
+			if s.State != Starting {
+				continue
 			}
+			if !ok {
+				// the server stays STARTING until its port answers
+				fmt.Printf("server %v did not answer status pings on port %v\n", s.JarPath, s.Port)
+				s.emit("status-unreachable", map[string]interface{}{"port": s.Port})
+				s.confirm()
+				continue
+			}
+			s.setState(Running)
+
+		case err := <-s.res:
+			close(s.exited)
+			s.removeCgroup()
+			s.closeRcon()
+			s.clearPlayers()
+			s.closeWatches()
+			s.ExitErr = err
+			// cleared before changing state as callbacks may start the server again
 			s.inputs = nil
 			s.logs = nil
 			s.res = nil
+			// a server killed after a stop request did not crash
+			var signaled = s.resetStop()
+			if err != nil && !signaled {
+				fmt.Printf("server %v exited with error: %v\n", s.JarPath, err)
+				s.setState(Crashed)
+			} else {
+				s.setState(Closed)
+			}
 			return
 
 		case in := <-s.inputs:
 			_, err := s.input.Write([]byte(in))
 			if err != nil && err != io.EOF {
-				panic(err)
+				fmt.Printf("failed to write command to server %v: %v\n", s.JarPath, err)
 			}
 		}
 	}
 }
 
-func (s *Server) listenServer() {
-	r := bufio.NewReader(s.output)
-	var str string
-	var err error
-	for {
-		str, err = r.ReadString('\n')
-		if err != nil { // should be only EOF
-			return
-		}
-		s.logs <- str
-	}
+type logLine struct {
+	str    string
+	stream Stream
 }
 
-func (s *Server) Zip(filename string) (snowflakes.ID, error) {
-	if s.State != Closed {
-		return "", ErrNotClosed
-	}
-	s.setState(Zipping)
-	defer s.setState(Closed)
+// Zip archives the server folder as a download (server must be closed), leaving out the entries excluded by rules
+func (s *Server) Zip(filename string, exclude *archive.Rules) (snowflakes.ID, error) {
+	var id snowflakes.ID
+	err := s.Maintain(Zipping, func() error {
+		wr, dl, err := downloads.NewFile(filename, 30*24*time.Hour)
+		if err != nil {
+			return err
+		}
+		err = archive.Archive(archive.Zip, filepath.Dir(s.JarPath), wr, archive.Options{Exclude: exclude})
+		if err != nil {
+			wr.Abort()
+			return err
+		}
+		id = dl
+		return wr.Close()
+	})
+	return id, err
+}
 
-	wr, id, err := downloads.NewFile(filename, 30*24*time.Hour)
-	if err != nil {
-		return id, err
+// Maintain sets the state of the closed server to st while f runs, so that it cannot be started meanwhile
+//
+// returns ErrNotClosed if the server is not closed
+func (s *Server) Maintain(st ServerState, f func() error) error {
+	s.statemu.Lock()
+	if !s.State.IsClosed() {
+		s.statemu.Unlock()
+		return ErrNotClosed
 	}
-
-	err = zip.Zip(filepath.Dir(s.JarPath), wr)
-	wr.Close()
-
-	return id, err
+	var prev = s.State
+	s.setState(st)
+	s.statemu.Unlock()
+	defer func() {
+		s.statemu.Lock()
+		s.setState(prev)
+		s.statemu.Unlock()
+	}()
+	return f()
 }
@@ -0,0 +1,99 @@
+package servers
+
+import (
+	"mineOS/status"
+	"time"
+)
+
+const (
+	// time given to the port to answer once the server reported it is done loading
+	confirmTimeout  = 30 * time.Second
+	confirmInterval = time.Second
+	// the status returned by GetStatus is refreshed this often
+	statusInterval = 15 * time.Second
+	statusTimeout  = 2 * time.Second
+)
+
+func (s *Server) host() string {
+	if s.Host == "" {
+		return "127.0.0.1"
+	}
+	return s.Host
+}
+
+// confirm starts confirmRunning unless it is already running (only called by processHandler)
+func (s *Server) confirm() {
+	if !s.confirming {
+		s.confirming = true
+		go s.confirmRunning(s.exited, s.confirmed)
+	}
+}
+
+// confirmRunning pings the server until it answers (true), confirmTimeout is reached (false) or the process exits
+func (s *Server) confirmRunning(exited chan struct{}, confirmed chan bool) {
+	var deadline = time.Now().Add(confirmTimeout)
+	for time.Now().Before(deadline) {
+		st, err := status.Ping(s.host(), s.Port, confirmInterval)
+		if err == nil {
+			s.setStatus(st)
+			select {
+			case confirmed <- true:
+			case <-exited:
+			}
+			return
+		}
+		select {
+		case <-exited:
+			return
+		case <-time.After(confirmInterval):
+		}
+	}
+	select {
+	case confirmed <- false:
+	case <-exited:
+	}
+}
+
+// GetStatus returns the result of the last status ping (nil if the server is not running or did not answer),
+// see probeStatus
+func (s *Server) GetStatus() *status.Status {
+	if s.State != Running {
+		return nil
+	}
+	s.statusmu.RLock()
+	defer s.statusmu.RUnlock()
+	return s.status
+}
+
+func (s *Server) setStatus(st *status.Status) {
+	s.statusmu.Lock()
+	s.status = st
+	s.statusmu.Unlock()
+}
+
+// probeStatus pings the server every statusInterval until it exits
+func (s *Server) probeStatus(exited chan struct{}) {
+	var ticker = time.NewTicker(statusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-exited:
+			s.setStatus(nil)
+			return
+		case <-ticker.C:
+		}
+		st, err := s.Status(statusTimeout)
+		if err != nil {
+			st = nil
+		}
+		s.setStatus(st)
+	}
+}
+
+// Status pings the server (which must be running) with the Server List Ping protocol
+func (s *Server) Status(timeout time.Duration) (*status.Status, error) {
+	if s.State != Running || s.Port == 0 {
+		return nil, ErrNotStarted
+	}
+	return status.Ping(s.host(), s.Port, timeout)
+}
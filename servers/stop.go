@@ -0,0 +1,116 @@
+package servers
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidStopTimeouts = fmt.Errorf("invalid stop timeouts")
+)
+
+// StopTimeouts configures how long Stop waits before escalating to signals
+type StopTimeouts struct {
+	// time given to the server to exit after the "stop" command before sending SIGTERM
+	GraceSeconds int `json:"grace-seconds"`
+	// time given to the server to exit after SIGTERM before sending SIGKILL
+	TermSeconds int `json:"term-seconds"`
+}
+
+func NewStopTimeouts() *StopTimeouts {
+	return &StopTimeouts{
+		GraceSeconds: 60,
+		TermSeconds:  30,
+	}
+}
+
+func (t *StopTimeouts) Validate() error {
+	if t.GraceSeconds <= 0 || t.TermSeconds <= 0 {
+		return fmt.Errorf("%w: timeouts must be positive", ErrInvalidStopTimeouts)
+	}
+	return nil
+}
+
+// SetStopTimeouts replaces the timeouts used by the next calls to Stop, t must not be modified afterwards
+func (s *Server) SetStopTimeouts(t *StopTimeouts) {
+	s.stopmu.Lock()
+	defer s.stopmu.Unlock()
+	s.StopTimeouts = t
+}
+
+// Stop asks the server to stop by sending the "stop" command; if it does not exit
+// within the grace period, SIGTERM and then SIGKILL are sent.
+//
+// if force is true, the "stop" command is skipped and SIGTERM is sent immediately
+func (s *Server) Stop(force bool) error {
+	if s.State != Starting && s.State != Running && s.State != Stopping {
+		return ErrNotStarted
+	}
+	if !force && s.State != Stopping {
+		err := s.SendCommand("stop")
+		if err != nil {
+			return err
+		}
+	}
+	s.stopmu.Lock()
+	defer s.stopmu.Unlock()
+	if s.escalation != nil {
+		if !force {
+			return nil
+		}
+		s.escalation.Stop() // forced stop of an already stopping server
+	}
+	var timeouts = s.StopTimeouts
+	if timeouts == nil {
+		timeouts = NewStopTimeouts()
+	}
+	var delay = time.Duration(timeouts.GraceSeconds) * time.Second
+	if force {
+		delay = 0
+	}
+	var exited = s.exited
+	s.escalation = time.AfterFunc(delay, func() {
+		s.escalate(exited, time.Duration(timeouts.TermSeconds)*time.Second)
+	})
+	return nil
+}
+
+func (s *Server) escalate(exited chan struct{}, term time.Duration) {
+	select {
+	case <-exited:
+		return
+	default:
+	}
+	s.signal(syscall.SIGTERM)
+	select {
+	case <-exited:
+		return
+	case <-time.After(term):
+	}
+	s.signal(syscall.SIGKILL)
+}
+
+func (s *Server) signal(sig syscall.Signal) {
+	s.stopmu.Lock()
+	s.signaled = true
+	s.stopmu.Unlock()
+	s.emit("stop-escalation", map[string]interface{}{"signal": sig.String()})
+	err := s.signalServer(sig)
+	if err != nil {
+		fmt.Printf("failed to send %v to server %v: %v\n", sig, s.JarPath, err)
+	}
+}
+
+// resetStop is called once the process exited, it returns true if the server was signaled
+func (s *Server) resetStop() bool {
+	s.stopmu.Lock()
+	defer s.stopmu.Unlock()
+	if s.escalation != nil {
+		s.escalation.Stop()
+		s.escalation = nil
+	}
+	var signaled = s.signaled
+	s.signaled = false
+	return signaled
+}
@@ -0,0 +1,371 @@
+package servers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Servers do not run as direct children of mineOS but under a small shell supervisor
+// started in its own session, so that they survive mineOS restarts.
+//
+// The supervisor keeps everything needed to reattach in SupervisorFolder (inside the server folder):
+//   - supervisor.pid and server.pid: pids of the supervisor and of the java process
+//   - stdin: named pipe read by the server (kept open by the supervisor so it never reads EOF)
+//   - stdout.log and stderr.log: output of the server, tailed by mineOS (and truncated once read past maxLogSize)
+//   - exit: exit code of the server, written by the supervisor once it exited
+//   - cgroup-error: why the supervisor could not join the cgroup of the server (only if it failed)
+//
+// The supervisor also joins the cgroup of the server and sets rlimits before starting it (see Limits).
+const (
+	SupervisorFolder = ".mineos"
+
+	supervisorName   = "mineos-supervisor"
+	supervisorScript = `dir=$1; shift
+if [ -n "$MINEOS_CGROUP" ]; then
+	if ! err=$(echo $$ 2>&1 >"$MINEOS_CGROUP/cgroup.procs"); then
+		echo "[mineOS] failed to join cgroup $MINEOS_CGROUP: $err" >>"$dir/stderr.log"
+		echo "$err" >"$dir/cgroup-error"
+	fi
+fi
+if [ -n "$MINEOS_NOFILE" ]; then
+	ulimit -n "$MINEOS_NOFILE" 2>>"$dir/stderr.log" || echo "[mineOS] failed to set open files limit" >>"$dir/stderr.log"
+fi
+if [ -n "$MINEOS_CORE" ]; then
+	ulimit -c "$MINEOS_CORE" 2>>"$dir/stderr.log" || echo "[mineOS] failed to set core dump limit" >>"$dir/stderr.log"
+fi
+exec 3<>"$dir/stdin"
+"$@" <&3 >>"$dir/stdout.log" 2>>"$dir/stderr.log" &
+echo $! > "$dir/server.pid"
+wait $!
+code=$?
+echo $code > "$dir/exit"
+exit $code`
+
+	tailInterval = 200 * time.Millisecond
+	pollInterval = time.Second
+
+	// output files are truncated once read past this size (they are only used to pass the output to mineOS)
+	maxLogSize = 64 << 20
+	// only the end of the output files is replayed on reattach
+	replayLimit = 1 << 20
+
+	// sent on the logs channel once the output of a reattached server has been replayed
+	replayDone Stream = "replay-done"
+)
+
+var (
+	ErrNotSupervised = fmt.Errorf("no supervised server process found")
+)
+
+func (s *Server) supervisorDir() string {
+	return filepath.Join(filepath.Dir(s.JarPath), SupervisorFolder)
+}
+
+func (s *Server) supervisorFile(name string) string {
+	return filepath.Join(s.supervisorDir(), name)
+}
+
+// startSupervised starts java with args under a new supervisor
+func (s *Server) startSupervised(java string, args []string) error {
+	var dir = s.supervisorDir()
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"stdout.log", "stderr.log", "exit", "server.pid", "supervisor.pid", "cgroup-error"} {
+		err = os.Remove(filepath.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	err = syscall.Mkfifo(filepath.Join(dir, "stdin"), 0600)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	// output files must exist before being tailed
+	for _, name := range []string{"stdout.log", "stderr.log"} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+
+	s.cmd = exec.Command("sh", append([]string{"-c", supervisorScript, supervisorName, dir, java}, args...)...)
+	s.cmd.Dir = filepath.Dir(s.JarPath)
+	s.cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	s.cmd.Env = s.supervisorEnv(s.Cgroup())
+	err = s.cmd.Start()
+	if err != nil {
+		return err
+	}
+	var pid = s.cmd.Process.Pid
+	err = os.WriteFile(filepath.Join(dir, "supervisor.pid"), []byte(strconv.Itoa(pid)), 0666)
+	if err != nil {
+		fmt.Printf("failed to write supervisor pid of server %v: %v\n", s.JarPath, err)
+	}
+	var cmd = s.cmd
+	err = s.attach(pid, false, cmd.Wait)
+	if err == nil {
+		go s.checkCgroupJoin(s.exited)
+	}
+	return err
+}
+
+// Reattach looks for a server left running by a previous mineOS process and attaches to it.
+//
+// the end of the output of the server (replayLimit) is replayed so that its running state and
+// scrollback are recovered, Replaying is true until all of it was read; replayed lines do not
+// trigger events, players are then recovered with the list command (see endReplay)
+//
+// returns ErrNotSupervised if there is no such server
+func (s *Server) Reattach() error {
+	s.statemu.Lock()
+	defer s.statemu.Unlock()
+	if !s.State.IsClosed() {
+		return ErrNotClosed
+	}
+	pid, err := readPid(s.supervisorFile("supervisor.pid"))
+	if err != nil || !isSupervisor(pid) {
+		return ErrNotSupervised
+	}
+	s.setCgroup(s.findCgroup(pid))
+	return s.attach(pid, true, func() error {
+		for processAlive(pid) {
+			time.Sleep(pollInterval)
+		}
+		data, err := os.ReadFile(s.supervisorFile("exit"))
+		if err != nil {
+			return fmt.Errorf("supervisor exited without exit code")
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return fmt.Errorf("invalid exit code: %q", data)
+		}
+		if code != 0 {
+			return fmt.Errorf("exit status %v", code)
+		}
+		return nil
+	})
+}
+
+// attach starts handling the supervised server; wait must return once the supervisor exited
+func (s *Server) attach(pid int, replay bool, wait func() error) error {
+	var err error
+	s.input, err = os.OpenFile(s.supervisorFile("stdin"), os.O_RDWR, 0) // does not block, unlike O_WRONLY
+	if err != nil {
+		return err
+	}
+	s.res = make(chan error, 1)
+	s.logs = make(chan logLine, 10)
+	s.inputs = make(chan string, 10)
+	s.exited = make(chan struct{})
+	s.confirmed = make(chan bool, 1)
+	s.lastLevel = Info
+	s.confirming = false
+	s.supervisorPid = pid
+
+	var stop = make(chan struct{})
+	var readers = &sync.WaitGroup{}
+	readers.Add(2)
+	if replay {
+		s.setReplaying(true)
+		var replayed = &sync.WaitGroup{}
+		replayed.Add(2)
+		go s.tail(s.supervisorFile("stdout.log"), Stdout, stop, readers, replayed)
+		go s.tail(s.supervisorFile("stderr.log"), Stderr, stop, readers, replayed)
+		go func(logs chan logLine, exited chan struct{}) {
+			replayed.Wait()
+			select {
+			case logs <- logLine{stream: replayDone}:
+			case <-exited:
+			}
+		}(s.logs, s.exited)
+	} else {
+		go s.tail(s.supervisorFile("stdout.log"), Stdout, stop, readers, nil)
+		go s.tail(s.supervisorFile("stderr.log"), Stderr, stop, readers, nil)
+	}
+
+	s.setState(Starting)
+
+	go s.sampleMetrics(pid, s.exited)
+	go s.probeStatus(s.exited)
+	go s.processHandler()
+	go func() {
+		err := wait()
+		close(stop)
+		readers.Wait()
+		s.input.Close()
+		s.res <- err
+	}()
+	return nil
+}
+
+// tail sends the lines of file until stop is closed and the end of file is reached
+//
+// replayed (if not nil) is marked done the first time the end of file is reached, only the
+// last replayLimit bytes of the file are then read
+func (s *Server) tail(file string, stream Stream, stop chan struct{}, wg *sync.WaitGroup, replayed *sync.WaitGroup) {
+	defer wg.Done()
+	if replayed != nil {
+		defer func() {
+			if replayed != nil {
+				replayed.Done()
+			}
+		}()
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Printf("failed to open %v: %v\n", file, err)
+		return
+	}
+	defer f.Close()
+	var r = bufio.NewReader(f)
+	var offset int64
+	if replayed != nil {
+		offset, err = skipToTail(f, r)
+		if err != nil {
+			fmt.Printf("failed to read %v: %v\n", file, err)
+			return
+		}
+	}
+	var partial string
+	for {
+		str, err := r.ReadString('\n')
+		partial += str
+		offset += int64(len(str))
+		if err == nil {
+			s.logs <- logLine{partial, stream}
+			partial = ""
+			continue
+		}
+		if err != io.EOF {
+			fmt.Printf("failed to read %v: %v\n", file, err)
+			return
+		}
+		if replayed != nil {
+			replayed.Done()
+			replayed = nil
+		}
+		if offset >= maxLogSize && truncateRead(file, offset) {
+			f.Seek(0, io.SeekStart)
+			r.Reset(f)
+			offset = 0
+		}
+		select {
+		case <-stop:
+			// the supervisor exited: everything has been written
+			if _, err := r.Peek(1); err == io.EOF {
+				if partial != "" {
+					s.logs <- logLine{partial + "\n", stream}
+				}
+				return
+			}
+		case <-time.After(tailInterval):
+		}
+	}
+}
+
+// skipToTail moves f (read by r) to the first line of its last replayLimit bytes and returns the new offset
+func skipToTail(f *os.File, r *bufio.Reader) (int64, error) {
+	info, err := f.Stat()
+	if err != nil || info.Size() <= replayLimit {
+		return 0, err
+	}
+	offset, err := f.Seek(info.Size()-replayLimit, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	r.Reset(f)
+	skipped, err := r.ReadString('\n')
+	return offset + int64(len(skipped)), err
+}
+
+// truncateRead empties file if all of it (size bytes) was read
+//
+// the server appends to the file so it keeps writing at the start; a line written
+// between the size check and the truncation is lost
+func truncateRead(file string, size int64) bool {
+	info, err := os.Stat(file)
+	if err != nil || info.Size() != size {
+		return false
+	}
+	err = os.Truncate(file, 0)
+	if err != nil {
+		fmt.Printf("failed to truncate %v: %v\n", file, err)
+		return false
+	}
+	return true
+}
+
+// endReplay is called once the output of a reattached server was replayed
+func (s *Server) endReplay() {
+	// the line telling that the server is done loading may be older than the replayed output
+	if s.State == Starting && s.Port != 0 {
+		s.confirm()
+	}
+	go func() {
+		s.connectRcon()
+		s.refreshPlayers()
+	}()
+}
+
+// signalServer sends sig to the java process (or to the whole supervisor
+// process group if its pid is unknown)
+func (s *Server) signalServer(sig syscall.Signal) error {
+	pid, err := readPid(s.supervisorFile("server.pid"))
+	if err != nil {
+		return syscall.Kill(-s.supervisorPid, sig)
+	}
+	return syscall.Kill(pid, sig)
+}
+
+func (s *Server) setReplaying(b bool) {
+	s.replaymu.Lock()
+	s.replaying = b
+	s.replaymu.Unlock()
+}
+
+// IsReplaying returns true while the output of a reattached server is being replayed
+func (s *Server) IsReplaying() bool {
+	s.replaymu.Lock()
+	defer s.replaymu.Unlock()
+	return s.replaying
+}
+
+func readPid(file string) (int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// isSupervisor checks that pid is alive and, when /proc is available, that it is a supervisor
+func isSupervisor(pid int) bool {
+	if pid <= 0 || !processAlive(pid) {
+		return false
+	}
+	if _, err := os.Stat("/proc/self"); err != nil {
+		return true // no procfs
+	}
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), supervisorName)
+}
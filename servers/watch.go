@@ -0,0 +1,67 @@
+package servers
+
+// logWatch waits for the first console record accepted by match
+type logWatch struct {
+	match func(*LogRecord) bool
+	ch    chan *LogRecord
+}
+
+// WatchLog returns a channel receiving the first parsed console record accepted by match,
+// cancel must be called once the channel is not needed anymore
+//
+// the channel is closed without any record if the server exits first
+func (s *Server) WatchLog(match func(*LogRecord) bool) (<-chan *LogRecord, func()) {
+	var w = &logWatch{match: match, ch: make(chan *LogRecord, 1)}
+	s.watchmu.Lock()
+	s.watches = append(s.watches, w)
+	s.watchmu.Unlock()
+	return w.ch, func() {
+		s.removeWatch(w, nil)
+	}
+}
+
+// removeWatch sends rec to w (closes its channel if rec is nil) and removes it,
+// does nothing if w was already removed
+func (s *Server) removeWatch(w *logWatch, rec *LogRecord) {
+	s.watchmu.Lock()
+	defer s.watchmu.Unlock()
+	for i, watch := range s.watches {
+		if watch != w {
+			continue
+		}
+		s.watches = append(s.watches[:i], s.watches[i+1:]...)
+		if rec != nil {
+			w.ch <- rec
+		}
+		close(w.ch)
+		return
+	}
+}
+
+func (s *Server) notifyWatches(rec *LogRecord) {
+	if !rec.Parsed {
+		return
+	}
+	s.watchmu.Lock()
+	var matched = []*logWatch{}
+	for _, w := range s.watches {
+		if w.match(rec) {
+			matched = append(matched, w)
+		}
+	}
+	s.watchmu.Unlock()
+	for _, w := range matched {
+		s.removeWatch(w, rec)
+	}
+}
+
+// closeWatches closes the channels of all watches once the server exited
+func (s *Server) closeWatches() {
+	s.watchmu.Lock()
+	var watches = s.watches
+	s.watches = nil
+	s.watchmu.Unlock()
+	for _, w := range watches {
+		close(w.ch)
+	}
+}
@@ -0,0 +1,211 @@
+package status
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidResponse = errors.New("invalid status response")
+)
+
+// Status is the result of a Server List Ping
+type Status struct {
+	MOTD      string `json:"motd"`
+	Version   string `json:"version"`
+	Protocol  int    `json:"protocol"`
+	Online    int    `json:"online"`
+	Max       int    `json:"max"`
+	LatencyMs int64  `json:"latency-ms"`
+}
+
+type response struct {
+	Version struct {
+		Name     string `json:"name"`
+		Protocol int    `json:"protocol"`
+	} `json:"version"`
+	Players struct {
+		Max    int `json:"max"`
+		Online int `json:"online"`
+	} `json:"players"`
+	Description json.RawMessage `json:"description"`
+}
+
+// chat component as used by the description field (which can also be a plain string)
+type chat struct {
+	Text  string `json:"text"`
+	Extra []chat `json:"extra"`
+}
+
+func (c chat) String() string {
+	var str = c.Text
+	for _, e := range c.Extra {
+		str += e.String()
+	}
+	return str
+}
+
+// Ping sends a Server List Ping (handshake, status request and ping) to host:port
+//
+// timeout applies to the whole exchange
+func Ping(host string, port int, timeout time.Duration) (*Status, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	var r = bufio.NewReader(conn)
+
+	// handshake (protocol version -1 as we do not know it) followed by status request
+	var handshake = &bytes.Buffer{}
+	writeVarInt(handshake, 0x00)
+	writeVarInt(handshake, -1)
+	writeString(handshake, host)
+	binary.Write(handshake, binary.BigEndian, uint16(port))
+	writeVarInt(handshake, 1)
+	err = writePacket(conn, handshake.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	err = writePacket(conn, []byte{0x00})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readPacket(r, 0x00)
+	if err != nil {
+		return nil, err
+	}
+	str, err := readString(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var resp = response{}
+	err = json.Unmarshal([]byte(str), &resp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	var st = &Status{
+		MOTD:     parseDescription(resp.Description),
+		Version:  resp.Version.Name,
+		Protocol: resp.Version.Protocol,
+		Online:   resp.Players.Online,
+		Max:      resp.Players.Max,
+	}
+
+	var ping = &bytes.Buffer{}
+	writeVarInt(ping, 0x01)
+	var sent = time.Now()
+	binary.Write(ping, binary.BigEndian, sent.UnixMilli())
+	err = writePacket(conn, ping.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	_, err = readPacket(r, 0x01)
+	if err != nil {
+		return nil, err
+	}
+	st.LatencyMs = time.Since(sent).Milliseconds()
+	return st, nil
+}
+
+func parseDescription(raw json.RawMessage) string {
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		return str
+	}
+	var c = chat{}
+	if json.Unmarshal(raw, &c) == nil {
+		return c.String()
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+func writePacket(w io.Writer, data []byte) error {
+	var buf = &bytes.Buffer{}
+	writeVarInt(buf, int32(len(data)))
+	buf.Write(data)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readPacket returns the payload of the next packet, which must have id as packet id
+func readPacket(r *bufio.Reader, id int32) ([]byte, error) {
+	size, err := readVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 || size > 1<<21 {
+		return nil, fmt.Errorf("%w: packet size %v", ErrInvalidResponse, size)
+	}
+	var data = make([]byte, size)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+	var br = bytes.NewReader(data)
+	pid, err := readVarInt(br)
+	if err != nil {
+		return nil, err
+	}
+	if pid != id {
+		return nil, fmt.Errorf("%w: expected packet %v but got %v", ErrInvalidResponse, id, pid)
+	}
+	return data[len(data)-br.Len():], nil
+}
+
+func writeVarInt(buf *bytes.Buffer, v int32) {
+	var u = uint32(v)
+	for {
+		if u&^0x7F == 0 {
+			buf.WriteByte(byte(u))
+			return
+		}
+		buf.WriteByte(byte(u&0x7F | 0x80))
+		u >>= 7
+	}
+}
+
+func readVarInt(r io.ByteReader) (int32, error) {
+	var v uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint32(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			return int32(v), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: varint too long", ErrInvalidResponse)
+}
+
+func writeString(buf *bytes.Buffer, str string) {
+	writeVarInt(buf, int32(len(str)))
+	buf.WriteString(str)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	size, err := readVarInt(r)
+	if err != nil {
+		return "", err
+	}
+	if size < 0 || int(size) > r.Len() {
+		return "", fmt.Errorf("%w: string size %v", ErrInvalidResponse, size)
+	}
+	var data = make([]byte, size)
+	_, err = io.ReadFull(r, data)
+	return string(data), err
+}
@@ -58,6 +58,9 @@ type Manifest interface {
 	DownloadServer(vrsID string, path string) error
 	GetType() ServerType
 
+	// returns the major java version required by vrsID (0 if unknown)
+	GetJavaVersion(vrsID string) (int, error)
+
 	// if vrsID does not exists, ClearCache should return nil
 	ClearCache(vrsID string) error
 
@@ -86,6 +89,15 @@ func GetVersionIdsBuServerType(srvType ServerType) ([]string, bool) {
 	return m.GetVersionsList(), true
 }
 
+// GetJavaVersion returns the major java version required by a minecraft version (0 if unknown)
+func GetJavaVersion(srvType ServerType, vrsID string) (int, error) {
+	m, ok := GetManifestByServerType(srvType)
+	if !ok {
+		return 0, ErrSrvTypeNotFound
+	}
+	return m.GetJavaVersion(vrsID)
+}
+
 func DownloadServerByServerType(srvType ServerType, vrsID string, path string) error {
 	m, ok := GetManifestByServerType(srvType)
 	if !ok {
@@ -22,6 +22,7 @@ type vanillaManifest struct {
 	Versions  []*vanillaVersion  `json:"versions"`
 	cacheVers []*vanillaCache    `json:"-"`
 	cacheDown []*vanillaDownload `json:"-"`
+	javaVers  map[string]int     `json:"-"` // major java version by version id
 	mu        sync.Mutex         `json:"-"`
 }
 
@@ -37,6 +38,7 @@ func vanillaGenerateManifest(offline bool) (Manifest, error) {
 		}
 	}
 	err = m.loadCache()
+	m.loadJavaCache()
 	return m, err
 }
 
@@ -100,11 +102,13 @@ func (d *vanillaDownload) download() error {
 				Url  string `json:"url"`
 			} `json:"server"`
 		} `json:"downloads"`
+		JavaVersion javaVersion `json:"javaVersion"`
 	}{}
 	err := RetrieveStructFromUrl(d.vers.URL, &meta)
 	if err != nil {
 		return err
 	}
+	d.m.setJavaVersion(d.vers.ID, meta.JavaVersion.major())
 
 	err = DownloadFile(path, meta.Downloads.Server.Url, meta.Downloads.Server.Size, meta.Downloads.Server.Sha1, sha1.New)
 	if err == nil {
@@ -322,3 +326,74 @@ func (m *vanillaManifest) ClearCache(vrsID string) error {
 	}
 	return err
 }
+
+type javaVersion struct {
+	MajorVersion int `json:"majorVersion"`
+}
+
+// version jsons without a java version are for versions running on java 8
+func (v javaVersion) major() int {
+	if v.MajorVersion == 0 {
+		return 8
+	}
+	return v.MajorVersion
+}
+
+// key of the java versions in the versions cache
+const vanillaJavaCacheKey = "VANILLA-java"
+
+// Locks mutexes
+func (m *vanillaManifest) loadJavaCache() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.javaVers = map[string]int{}
+	inter, ok := cacheGet(vanillaJavaCacheKey)
+	if !ok {
+		return
+	}
+	vers, ok := inter.(map[string]interface{})
+	if !ok {
+		fmt.Printf("invalid java versions in vanilla cache (got type: %T)\n", inter)
+		return
+	}
+	for id, v := range vers {
+		if major, ok := v.(float64); ok {
+			m.javaVers[id] = int(major)
+		}
+	}
+}
+
+// Locks mutexes
+func (m *vanillaManifest) setJavaVersion(vrsID string, major int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.javaVers[vrsID] = major
+	var vers = map[string]int{}
+	for id, v := range m.javaVers {
+		vers[id] = v
+	}
+	cachePut(vanillaJavaCacheKey, vers)
+}
+
+// GetJavaVersion reads javaVersion.majorVersion from the version json (results are cached)
+func (m *vanillaManifest) GetJavaVersion(vrsID string) (int, error) {
+	m.mu.Lock()
+	if major, ok := m.javaVers[vrsID]; ok {
+		m.mu.Unlock()
+		return major, nil
+	}
+	vrs, ok := m.getVersion(vrsID)
+	m.mu.Unlock()
+	if !ok {
+		return 0, ErrVerIdNotFound
+	}
+	var meta = struct {
+		JavaVersion javaVersion `json:"javaVersion"`
+	}{}
+	err := RetrieveStructFromUrl(vrs.URL, &meta)
+	if err != nil {
+		return 0, err
+	}
+	m.setJavaVersion(vrsID, meta.JavaVersion.major())
+	return meta.JavaVersion.major(), nil
+}